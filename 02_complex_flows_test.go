@@ -185,7 +185,7 @@ func TestMaxTokensStopReason(t *testing.T) {
 		}).Ignore("message.content.*.text"),
 		defaultResultPattern(func(m *ResultSuccessMessage) {
 			m.IsError = true
-			m.StopReason = "end_turn"
+			m.StopReason = NewNullable("end_turn")
 		}).Ignore("stop_reason"),
 	)
 }
@@ -231,3 +231,66 @@ func TestMultipleTextBlocks(t *testing.T) {
 		}).Assert("result"),
 	)
 }
+
+// Responses routed by request content instead of request order
+func TestRuleRoutedResponses(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{T: t, Rules: []utils.Rule{
+		// Checked first: the message of turn 2 may share the last user
+		// message with the tool_result of turn 1.
+		{
+			LastUser: "say bye",
+			Response: utils.TextResponse("Bye."),
+		},
+		// The request after the Bash execution carries its tool_result.
+		{
+			ToolResult: "toolu_rule_001",
+			Response:   utils.TextResponse("The command printed rule-routed."),
+		},
+		{
+			LastUser: "run the command",
+			Tools:    []string{"Bash"},
+			Response: utils.ToolUseResponse("toolu_rule_001", "Bash", map[string]any{
+				"command":     "echo rule-routed",
+				"description": "Print a marker",
+			}),
+		},
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	// Turn 1
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "run the command"},
+	}))
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{
+				{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+					ToolUseID:        "toolu_rule_001",
+					Content:          "rule-routed",
+				},
+			}
+		}),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "The command printed rule-routed."
+		}).Assert("result"),
+	)
+
+	// Turn 2
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "say bye"},
+	}))
+	utils.AssertOutput(t, s.Read(),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Bye."
+		}).Assert("result"),
+	)
+}
@@ -381,3 +381,56 @@ func TestLongToolChain(t *testing.T) {
 		t.Errorf("expected chain file to contain 'modified-content', got: %s", string(content))
 	}
 }
+
+// Multi-step tool chain scripted from the tool_results of each request
+func TestToolChainResponder(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{T: t, Responder: func(rec utils.RecordedRequest) []utils.SSEEvent {
+		req, err := rec.Parse()
+		if err != nil {
+			t.Errorf("parse request: %v", err)
+			return nil
+		}
+		results := req.ToolResults()
+		if len(results) == 0 {
+			return utils.ToolUseResponse("toolu_resp_001", "Bash", map[string]any{
+				"command":     "echo step-one",
+				"description": "First step",
+			})
+		}
+		// Each step builds on the output of the previous one.
+		last := results[len(results)-1]
+		if last.ToolUseID == "toolu_resp_001" {
+			return utils.ToolUseResponse("toolu_resp_002", "Bash", map[string]any{
+				"command":     "echo " + last.Content.Text() + " step-two",
+				"description": "Second step",
+			})
+		}
+		return utils.TextResponse("Last tool result: " + last.Content.Text())
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "run the two steps"},
+	}))
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{
+				{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+					ToolUseID:        "toolu_resp_002",
+					Content:          "step-one step-two",
+				},
+			}
+		}),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Last tool result: step-one step-two"
+		}).Assert("result"),
+	)
+}
@@ -271,3 +271,70 @@ func TestToolUseTaskUpdate(t *testing.T) {
 		}).Assert("result"),
 	)
 }
+
+// Task subagent messages nest under the Task tool_use
+func TestToolUseTaskSubagentTree(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: Task tool_use spawning a subagent
+		utils.ToolUseResponse("toolu_task_001", "Task", map[string]any{
+			"description":   "Say hello",
+			"prompt":        "Say hello and finish",
+			"subagent_type": "general-purpose",
+		}),
+		// Request 2: the subagent's reply
+		utils.TextResponse("Hello from subagent."),
+		// Request 3: final text from the main agent
+		utils.TextResponse("Subagent finished."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSessionWithEnv(t, stub.URL(), []string{enableTasksEnv})
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "delegate a greeting"},
+	}))
+	turn := &Turn{}
+	for i, raw := range s.Read() {
+		msg, err := DecodeMessage(raw, Lenient())
+		if err != nil {
+			t.Fatalf("decode output[%d]: %v", i, err)
+		}
+		turn.Add(msg)
+	}
+
+	// Observed: The subagent's assistant messages carry the Task tool_use ID
+	// as parent_tool_use_id; the Task tool_result is a top-level user message.
+	tree := turn.Tree()
+	if len(tree.Root.Children) != 1 {
+		t.Fatalf("len(Root.Children) = %d, want 1", len(tree.Root.Children))
+	}
+	sub := tree.Root.Children[0]
+	if sub.ToolUse.ID != "toolu_task_001" || sub.ToolUse.Name != ToolTask {
+		t.Errorf("subagent ToolUse = %+v, want the Task tool_use", sub.ToolUse)
+	}
+	if sub.Result == nil {
+		t.Error("subagent Result = nil, want the Task tool_result")
+	}
+	var texts []string
+	for _, m := range sub.Assistant() {
+		for _, b := range m.Message.Content {
+			if tb, ok := b.(TextBlock); ok {
+				texts = append(texts, tb.Text)
+			}
+		}
+	}
+	if len(texts) != 1 || texts[0] != "Hello from subagent." {
+		t.Errorf("subagent texts = %q, want [Hello from subagent.]", texts)
+	}
+	if got := turn.Text(); got != "Subagent finished." {
+		t.Errorf("top-level text = %q, want %q", got, "Subagent finished.")
+	}
+	// The stub reports the same usage for every response.
+	if sub, root := sub.Usage(), tree.Root.Usage(); sub.InputTokens == 0 || root.InputTokens != 3*sub.InputTokens {
+		t.Errorf("Usage() root = %+v, subagent = %+v, want root to total three responses", root, sub)
+	}
+}
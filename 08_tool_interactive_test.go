@@ -134,11 +134,11 @@ func TestAskUserQuestionSuccess(t *testing.T) {
 		}).Ignore("message.content.*.id", "message.content.*.input"),
 		// stdout: CLI asks for permission
 		defaultControlRequestPattern(func(m *ControlRequestMessage) {
-			m.Request = ControlRequest{
-				Subtype:   ControlCanUseTool,
-				ToolName:  "AskUserQuestion",
-				Input:     map[string]any{"command": "echo hello", "description": "Example"},
-				ToolUseID: "toolu_stub_001",
+			m.Request = CanUseToolRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+				ToolName:           "AskUserQuestion",
+				Input:              map[string]any{"command": "echo hello", "description": "Example"},
+				ToolUseID:          "toolu_stub_001",
 			}
 		}).Ignore("request.input", "request.tool_use_id"),
 	)
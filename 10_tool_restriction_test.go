@@ -161,4 +161,8 @@ func TestDisallowedToolsRestriction(t *testing.T) {
 	if _, err := os.Stat(targetFile); err == nil {
 		t.Error("file should not have been created when Write tool is disallowed")
 	}
+	// The disallowed tools are not offered to the API either.
+	for _, req := range stub.MessagesRequests(t) {
+		utils.AssertToolsNotOffered(t, req, "Write", "Edit")
+	}
 }
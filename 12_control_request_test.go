@@ -38,9 +38,9 @@ func TestControlSetPermissionMode(t *testing.T) {
 	s.Send(utils.MustJSON(ControlRequestMessage{
 		MessageBase: MessageBase{Type: TypeControlRequest},
 		RequestID:   "test-perm-001",
-		Request: ControlRequest{
-			Subtype: ControlSetPermissionMode,
-			Mode:    "plan",
+		Request: SetPermissionModeRequest{
+			ControlRequestBase: ControlRequestBase{Subtype: ControlSetPermissionMode},
+			Mode:               PermissionPlan,
 		},
 	}))
 
@@ -95,9 +95,9 @@ func TestControlSetModel(t *testing.T) {
 	s.Send(utils.MustJSON(ControlRequestMessage{
 		MessageBase: MessageBase{Type: TypeControlRequest},
 		RequestID:   "test-model-001",
-		Request: ControlRequest{
-			Subtype: ControlSetModel,
-			Model:   "sonnet",
+		Request: SetModelRequest{
+			ControlRequestBase: ControlRequestBase{Subtype: ControlSetModel},
+			Model:              "sonnet",
 		},
 	}))
 
@@ -116,3 +116,66 @@ func TestControlSetModel(t *testing.T) {
 		defaultResultPattern(),
 	)
 }
+
+// Thinking budget change via set_max_thinking_tokens control request
+func TestControlSetMaxThinkingTokens(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Turn 1
+		utils.TextResponse("Ready."),
+		// Turn 2 (after the budget change)
+		utils.TextResponse("Acknowledged."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	// The budget only applies to models without adaptive thinking.
+	s := utils.NewSessionWithFlags(t, stub.URL(),
+		[]string{"--model", "claude-sonnet-4-5"}, nil)
+	defer s.Close()
+
+	// Turn 1: normal flow
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern().Ignore("model"),
+		defaultResultPattern(func(m *ResultSuccessMessage) { m.Result = "Ready." }).Assert("result"),
+	)
+
+	// Send control_request to set the thinking budget
+	s.Send(utils.MustJSON(ControlRequestMessage{
+		MessageBase: MessageBase{Type: TypeControlRequest},
+		RequestID:   "test-thinking-001",
+		Request: SetMaxThinkingTokensRequest{
+			ControlRequestBase: ControlRequestBase{Subtype: ControlSetMaxThinkingTokens},
+			MaxThinkingTokens:  NewNullable(2048),
+		},
+	}))
+
+	// Turn 2: control_response + new system/init + normal flow
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hi"},
+	}))
+	// Observed: The CLI acknowledges the control_request, re-inits, and sends
+	// the next API request with thinking enabled at the requested budget.
+	utils.AssertOutput(t, s.Read(),
+		defaultControlResponsePattern(),
+		defaultInitPattern().Ignore("model"),
+		defaultResultPattern(func(m *ResultSuccessMessage) { m.Result = "Acknowledged." }).Assert("result"),
+	)
+
+	reqs := stub.MessagesRequests(t)
+	if len(reqs) == 0 {
+		t.Fatal("no API request recorded")
+	}
+	last := reqs[len(reqs)-1]
+	if last.Thinking == nil {
+		t.Fatal("last API request has no thinking config")
+	}
+	if last.Thinking.Type != "enabled" || last.Thinking.BudgetTokens != 2048 {
+		t.Errorf("thinking = %+v, want {Type:enabled BudgetTokens:2048}", *last.Thinking)
+	}
+}
@@ -2,6 +2,7 @@ package ccprotocol_test
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -102,6 +103,27 @@ func TestIncludePartialMessages(t *testing.T) {
 	if len(output) <= 3 {
 		t.Errorf("expected more than 3 messages with partial streaming, got %d", len(output))
 	}
+
+	// The stream events rebuild the content of the assistant message.
+	a := &StreamAssembler{}
+	var want []IsContentBlock
+	for i, raw := range output {
+		msg, err := DecodeMessage(raw, Lenient())
+		if err != nil {
+			t.Fatalf("decode output[%d]: %v", i, err)
+		}
+		switch m := msg.(type) {
+		case *StreamEventMessage:
+			if err := a.Add(m); err != nil {
+				t.Fatalf("assemble output[%d]: %v", i, err)
+			}
+		case *AssistantMessage:
+			want = append(want, m.Message.Content...)
+		}
+	}
+	if got := a.Message().Content; !a.Done() || !reflect.DeepEqual(got, want) {
+		t.Errorf("assembled content = %#v (done %v), want %#v", got, a.Done(), want)
+	}
 }
 
 // Turn limit behavior via --max-turns flag
@@ -197,6 +219,17 @@ func TestToolsRestriction(t *testing.T) {
 	if !initFound {
 		t.Fatal("system/init message not found")
 	}
+
+	// The tool definitions sent to the API are restricted the same way.
+	reqs := stub.MessagesRequests(t)
+	if len(reqs) == 0 {
+		t.Fatal("no API request recorded")
+	}
+	utils.AssertToolsOffered(t, reqs[0], "Bash", "Read")
+	utils.AssertToolsNotOffered(t, reqs[0], "Write", "Edit")
+	if bash := reqs[0].Tool("Bash"); bash == nil || bash.InputSchema["type"] != "object" {
+		t.Errorf("Bash tool definition = %+v, want an object input_schema", bash)
+	}
 }
 
 // Permission mode variation via --permission-mode flag
@@ -105,6 +105,44 @@ func TestAppendSystemPrompt(t *testing.T) {
 	}
 }
 
+// Custom system prompt kept on every turn
+func TestSystemPromptEveryTurn(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Turn 1
+		utils.TextResponse("First."),
+		// Turn 2
+		utils.TextResponse("Second."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSessionWithFlags(t, stub.URL(),
+		[]string{"--system-prompt", "You are a test bot"}, nil)
+	defer s.Close()
+
+	for _, turn := range []struct{ prompt, result string }{
+		{"hello", "First."},
+		{"again", "Second."},
+	} {
+		s.Send(utils.MustJSON(UserTextMessage{
+			MessageBase: MessageBase{Type: TypeUser},
+			Message:     UserTextBody{Role: RoleUser, Content: turn.prompt},
+		}))
+		s.Read()
+	}
+
+	// Observed: The custom system prompt is sent again on the second turn, not
+	// only on the request that starts the session.
+	reqs := stub.MessagesRequests(t)
+	if len(reqs) < 2 {
+		t.Fatalf("got %d API requests, want at least 2", len(reqs))
+	}
+	for _, req := range reqs {
+		utils.AssertSystemPromptContains(t, req, "You are a test bot")
+	}
+}
+
 // Session ID override via --session-id flag
 func TestSessionID(t *testing.T) {
 	t.Parallel()
@@ -52,13 +52,25 @@ func TestBashPermissionApproved(t *testing.T) {
 		// The request includes permission_suggestions (suggested rules) and
 		// blocked_path (the filesystem path that triggered the check).
 		defaultControlRequestPattern(func(m *ControlRequestMessage) {
-			m.Request = ControlRequest{
-				Subtype:               ControlCanUseTool,
-				ToolName:              "Bash",
-				Input:                 map[string]any{"command": "rm -f /tmp/ccprotocol_perm_test_file", "description": "Remove test file"},
-				ToolUseID:             "toolu_stub_001",
-				PermissionSuggestions: []string{"allow:Bash(/tmp/*)"},
-				BlockedPath:           "/tmp/ccprotocol_perm_test_file",
+			m.Request = CanUseToolRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+				ToolName:           "Bash",
+				Input:              map[string]any{"command": "rm -f /tmp/ccprotocol_perm_test_file", "description": "Remove test file"},
+				ToolUseID:          "toolu_stub_001",
+				PermissionSuggestions: []PermissionUpdate{
+					{
+						Type:        PermissionUpdateAddRules,
+						Rules:       []PermissionRuleValue{{ToolName: "Bash", RuleContent: "rm -f /tmp/ccprotocol_perm_test_file"}},
+						Behavior:    "allow",
+						Destination: DestinationLocalSettings,
+					},
+					{
+						Type:        PermissionUpdateAddDirectories,
+						Directories: []string{"/tmp"},
+						Destination: DestinationSession,
+					},
+				},
+				BlockedPath: "/tmp/ccprotocol_perm_test_file",
 			}
 		}).Ignore("request.input", "request.tool_use_id", "request.permission_suggestions", "request.blocked_path"),
 	)
@@ -143,13 +155,18 @@ func TestBashPermissionDenied(t *testing.T) {
 		}).Ignore("message.content.*.id", "message.content.*.input"),
 		// stdout: CLI asks for permission to run Bash
 		defaultControlRequestPattern(func(m *ControlRequestMessage) {
-			m.Request = ControlRequest{
-				Subtype:               ControlCanUseTool,
-				ToolName:              "Bash",
-				Input:                 map[string]any{"command": "rm -rf /", "description": "Dangerous command"},
-				ToolUseID:             "toolu_stub_001",
-				PermissionSuggestions: []string{"allow:Bash(rm)"},
-				DecisionReason:        "Command requires permissions",
+			m.Request = CanUseToolRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+				ToolName:           "Bash",
+				Input:              map[string]any{"command": "rm -rf /", "description": "Dangerous command"},
+				ToolUseID:          "toolu_stub_001",
+				PermissionSuggestions: []PermissionUpdate{{
+					Type:        PermissionUpdateAddRules,
+					Rules:       []PermissionRuleValue{{ToolName: "Bash", RuleContent: "rm -rf /"}},
+					Behavior:    "allow",
+					Destination: DestinationLocalSettings,
+				}},
+				DecisionReason: "Command requires permissions",
 			}
 		}).Ignore("request.input", "request.tool_use_id", "request.permission_suggestions", "request.decision_reason"),
 	)
@@ -199,3 +216,246 @@ func TestBashPermissionDenied(t *testing.T) {
 		}).Assert("result").Ignore("permission_denials.*.tool_use_id", "permission_denials.*.tool_input"),
 	)
 }
+
+// "Always allow" via updatedPermissions in the permission response.
+// The response applies the CLI's suggestions (a rule for the exact command
+// and /tmp as a working directory) scoped to the session, so the CLI runs the
+// second identical Bash call without another control_request.
+func TestBashPermissionAlwaysAllow(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: Bash tool_use (triggers a permission prompt)
+		utils.ToolUseResponse("toolu_always_001", "Bash", map[string]any{"command": "rm -f /tmp/ccprotocol_perm_always_file", "description": "Remove test file"}),
+		// Request 2: The same Bash tool_use again (allowed by the remembered rule)
+		utils.ToolUseResponse("toolu_always_002", "Bash", map[string]any{"command": "rm -f /tmp/ccprotocol_perm_always_file", "description": "Remove test file"}),
+		// Request 3: Final text
+		utils.TextResponse("Removed twice."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSessionWithPermissionHandler(t, stub.URL(), nil)
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "remove the test file twice"},
+	}))
+
+	// Phase 1: Read until the CLI asks for permission via control_request.
+	output1 := s.ReadUntil("control_request")
+	utils.AssertOutput(t, output1,
+		defaultInitPattern(func(m *SystemInitMessage) { m.PermissionMode = PermissionDefault }),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				ToolUseBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolUse},
+					ID:               "toolu_stub_001",
+					Name:             "Bash",
+					Input:            map[string]any{"command": "rm -f /tmp/ccprotocol_perm_always_file", "description": "Remove test file"},
+				},
+			}
+		}).Ignore("message.content.*.id"),
+		defaultControlRequestPattern(func(m *ControlRequestMessage) {
+			m.Request = CanUseToolRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+				ToolName:           "Bash",
+				Input:              map[string]any{"command": "rm -f /tmp/ccprotocol_perm_always_file", "description": "Remove test file"},
+				ToolUseID:          "toolu_stub_001",
+				PermissionSuggestions: []PermissionUpdate{
+					{
+						Type:        PermissionUpdateAddRules,
+						Rules:       []PermissionRuleValue{{ToolName: "Bash", RuleContent: "rm -f /tmp/ccprotocol_perm_always_file"}},
+						Behavior:    "allow",
+						Destination: DestinationLocalSettings,
+					},
+					{
+						Type:        PermissionUpdateAddDirectories,
+						Directories: []string{"/tmp"},
+						Destination: DestinationSession,
+					},
+				},
+				BlockedPath: "/tmp/ccprotocol_perm_always_file",
+			}
+		}).Ignore("request.tool_use_id", "request.permission_suggestions", "request.blocked_path"),
+	)
+
+	// Phase 2: Approve with the suggested updates. The session destination
+	// keeps the rule out of the settings files.
+	reqID := utils.ExtractRequestID(output1[len(output1)-1])
+	s.Send(utils.MustJSON(ControlResponseMessage{
+		MessageBase: MessageBase{Type: TypeControlResponse},
+		Response: ControlResponseBody{
+			Subtype:   "success",
+			RequestID: reqID,
+			Response: PermissionPayload{
+				Behavior:     "allow",
+				UpdatedInput: map[string]any{"command": "rm -f /tmp/ccprotocol_perm_always_file", "description": "Remove test file"},
+				UpdatedPermissions: []PermissionUpdate{
+					{
+						Type:        PermissionUpdateAddRules,
+						Rules:       []PermissionRuleValue{{ToolName: "Bash", RuleContent: "rm -f /tmp/ccprotocol_perm_always_file"}},
+						Behavior:    "allow",
+						Destination: DestinationSession,
+					},
+					{
+						Type:        PermissionUpdateAddDirectories,
+						Directories: []string{"/tmp"},
+						Destination: DestinationSession,
+					},
+				},
+			},
+		},
+	}))
+
+	// Phase 3: Both calls run; the second one without a control_request.
+	output2 := s.Read()
+	utils.AssertOutput(t, output2,
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{{
+				ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+				ToolUseID:        "toolu_stub_001",
+				Content:          "(Bash completed with no output)",
+			}}
+		}).Ignore("message.content.*.tool_use_id"),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				ToolUseBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolUse},
+					ID:               "toolu_stub_002",
+					Name:             "Bash",
+					Input:            map[string]any{"command": "rm -f /tmp/ccprotocol_perm_always_file", "description": "Remove test file"},
+				},
+			}
+		}).Ignore("message.content.*.id"),
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{{
+				ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+				ToolUseID:        "toolu_stub_002",
+				Content:          "(Bash completed with no output)",
+			}}
+		}).Ignore("message.content.*.tool_use_id"),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "Removed twice.",
+				},
+			}
+		}),
+		// result: no permission denials
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Removed twice."
+		}).Assert("result"),
+	)
+}
+
+// Permission mode change via updatedPermissions in the permission response.
+// A setMode update switches the session to acceptEdits (announced with a
+// system/status message) and addDirectories makes /tmp a working directory,
+// so the following Write to /tmp runs without a control_request.
+func TestPermissionUpdateSetMode(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: Bash tool_use (triggers a permission prompt)
+		utils.ToolUseResponse("toolu_mode_001", "Bash", map[string]any{"command": "rm -f /tmp/ccprotocol_perm_mode_file", "description": "Remove test file"}),
+		// Request 2: Write tool_use (allowed by acceptEdits)
+		utils.ToolUseResponse("toolu_mode_002", "Write", map[string]any{"file_path": "/tmp/ccprotocol_perm_mode_file", "content": "hello"}),
+		// Request 3: Final text
+		utils.TextResponse("File written."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSessionWithPermissionHandler(t, stub.URL(), nil)
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "recreate the test file"},
+	}))
+
+	// Phase 1: Read until the CLI asks for permission via control_request.
+	output1 := s.ReadUntil("control_request")
+	utils.AssertOutput(t, output1,
+		defaultInitPattern(func(m *SystemInitMessage) { m.PermissionMode = PermissionDefault }),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				ToolUseBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolUse},
+					ID:               "toolu_stub_001",
+					Name:             "Bash",
+					Input:            map[string]any{"command": "rm -f /tmp/ccprotocol_perm_mode_file", "description": "Remove test file"},
+				},
+			}
+		}).Ignore("message.content.*.id"),
+		defaultControlRequestPattern(func(m *ControlRequestMessage) {
+			m.Request = CanUseToolRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+				ToolName:           "Bash",
+				Input:              map[string]any{"command": "rm -f /tmp/ccprotocol_perm_mode_file", "description": "Remove test file"},
+				ToolUseID:          "toolu_stub_001",
+			}
+		}).Ignore("request.tool_use_id", "request.permission_suggestions", "request.blocked_path"),
+	)
+
+	// Phase 2: Approve and switch to acceptEdits with /tmp as a working
+	// directory, both for this session only.
+	reqID := utils.ExtractRequestID(output1[len(output1)-1])
+	s.Send(utils.MustJSON(ControlResponseMessage{
+		MessageBase: MessageBase{Type: TypeControlResponse},
+		Response: ControlResponseBody{
+			Subtype:   "success",
+			RequestID: reqID,
+			Response: PermissionPayload{
+				Behavior:     "allow",
+				UpdatedInput: map[string]any{"command": "rm -f /tmp/ccprotocol_perm_mode_file", "description": "Remove test file"},
+				UpdatedPermissions: []PermissionUpdate{
+					{Type: PermissionUpdateSetMode, Mode: PermissionAcceptEdits, Destination: DestinationSession},
+					{Type: PermissionUpdateAddDirectories, Directories: []string{"/tmp"}, Destination: DestinationSession},
+				},
+			},
+		},
+	}))
+
+	// Phase 3: The mode change is announced, then both tools run.
+	output2 := s.Read()
+	utils.AssertOutput(t, output2,
+		defaultSystemStatusPattern(func(m *SystemStatusMessage) { m.PermissionMode = PermissionAcceptEdits }),
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{{
+				ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+				ToolUseID:        "toolu_stub_001",
+				Content:          "(Bash completed with no output)",
+			}}
+		}).Ignore("message.content.*.tool_use_id"),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				ToolUseBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolUse},
+					ID:               "toolu_stub_002",
+					Name:             "Write",
+					Input:            map[string]any{"file_path": "/tmp/ccprotocol_perm_mode_file", "content": "hello"},
+				},
+			}
+		}).Ignore("message.content.*.id"),
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{{
+				ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+				ToolUseID:        "toolu_stub_002",
+				Content:          "File created successfully at: /tmp/ccprotocol_perm_mode_file",
+			}}
+		}).Ignore("message.content.*.tool_use_id", "message.content.*.content"),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "File written.",
+				},
+			}
+		}),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "File written."
+		}).Assert("result"),
+	)
+}
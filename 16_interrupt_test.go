@@ -0,0 +1,148 @@
+// > Interrupting a turn with the `interrupt` control request.
+// > The stub API stalls its response so the interrupt arrives mid-turn.
+package ccprotocol_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+	"github.com/hrntknr/claudecodeprotocol/utils"
+)
+
+// Interrupt while the API response is still streaming
+func TestInterruptStreaming(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: text block that never finishes
+		utils.StalledTextResponse("Working on"),
+		// Request 2: next turn after the interrupt
+		utils.TextResponse("Resumed."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "write a long story"},
+	}))
+	select {
+	case <-stub.Stalled():
+	case <-time.After(30 * time.Second):
+		t.Fatal("API response did not stall")
+	}
+
+	// Observed: The CLI answers the interrupt with a control_response whose
+	// payload lists queued messages (still_queued), then ends the turn with
+	// result/error_during_execution. It also emits a user message with the
+	// text block "[Request interrupted by user]" before the result.
+	utils.AssertOutput(t, s.Interrupt(),
+		defaultInitPattern(),
+		defaultControlResponsePattern(func(m *ControlResponseMessage) {
+			m.Response.Response = map[string]any{"still_queued": []any{}}
+		}).Ignore("response.response"),
+		defaultUserContentPattern(func(m *UserContentMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "[Request interrupted by user]",
+				},
+			}
+		}),
+		defaultResultErrorPattern(func(m *ResultErrorMessage) {
+			m.IsError = true
+		}).Ignore("errors"),
+	)
+
+	// The session stays usable: the next message starts a normal turn.
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "continue"},
+	}))
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultResultPattern(func(m *ResultSuccessMessage) { m.Result = "Resumed." }).Assert("result"),
+	)
+}
+
+// Interrupt while a tool is running
+func TestInterruptToolUse(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: long-running Bash command
+		utils.ToolUseResponse("toolu_sleep_001", "Bash", map[string]any{
+			"command":     "sleep 30",
+			"description": "Wait",
+		}),
+		// Request 2: next turn after the interrupt
+		utils.TextResponse("Resumed."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "wait for a while"},
+	}))
+	// The tool starts once the assistant tool_use message is emitted.
+	utils.AssertOutput(t, s.ReadUntil("assistant"),
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				ToolUseBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolUse},
+					ID:               "toolu_sleep_001",
+					Name:             "Bash",
+					Input:            map[string]any{"command": "sleep 30", "description": "Wait"},
+				},
+			}
+		}),
+	)
+
+	// Observed: The CLI answers the interrupt, then reports the aborted tool
+	// call as an error tool_result (the content tells the model the user
+	// rejected the tool use), followed by a user message with the text block
+	// "[Request interrupted by user for tool use]" and
+	// result/error_during_execution.
+	utils.AssertOutput(t, s.Interrupt(),
+		defaultControlResponsePattern(func(m *ControlResponseMessage) {
+			m.Response.Response = map[string]any{"still_queued": []any{}}
+		}).Ignore("response.response"),
+		defaultUserToolResultPattern(func(m *UserToolResultMessage) {
+			m.Message.Content = []ToolResultBlock{
+				{
+					ContentBlockBase: ContentBlockBase{Type: BlockToolResult},
+					ToolUseID:        "toolu_sleep_001",
+					Content:          "The user doesn't want to proceed with this tool use.",
+					IsError:          true,
+				},
+			}
+		}).Ignore("message.content.0.content"),
+		defaultUserContentPattern(func(m *UserContentMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "[Request interrupted by user for tool use]",
+				},
+			}
+		}),
+		defaultResultErrorPattern(func(m *ResultErrorMessage) {
+			m.IsError = true
+		}).Ignore("errors"),
+	)
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "continue"},
+	}))
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultResultPattern(func(m *ResultSuccessMessage) { m.Result = "Resumed." }).Assert("result"),
+	)
+}
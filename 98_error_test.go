@@ -1,7 +1,10 @@
 package ccprotocol_test
 
 import (
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/hrntknr/claudecodeprotocol"
 	"github.com/hrntknr/claudecodeprotocol/utils"
@@ -75,3 +78,418 @@ func TestAPIError(t *testing.T) {
 		}).Ignore("errors"),
 	)
 }
+
+// Retry after HTTP 429 with a retry-after header
+func TestHTTPRateLimitRetry(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: rate limited, retry after 1 second
+		utils.HTTPErrorResponse(429, map[string]string{"retry-after": "1"}, "rate_limit_error", "Number of request tokens has exceeded your rate limit."),
+		// Request 2: the retry succeeds
+		utils.TextResponse("Recovered after rate limit."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	start := time.Now()
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	// Observed: The CLI retries the request within the same turn, waiting
+	// for the retry-after delay first. The failed attempt leaves no trace in
+	// the result: the turn ends with subtype "success".
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "Recovered after rate limit.",
+				},
+			}
+		}),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Recovered after rate limit."
+		}).Assert("result"),
+	)
+	if got := stub.RequestCount(); got != 2 {
+		t.Errorf("RequestCount() = %d, want 2 (the 429 and its retry)", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("turn took %v, want the 1s retry-after delay honored", elapsed)
+	}
+}
+
+// Retry after HTTP 529 overloaded
+func TestHTTPOverloadedRetry(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: overloaded
+		utils.HTTPErrorResponse(529, nil, "overloaded_error", "Overloaded"),
+		// Request 2: the retry succeeds
+		utils.TextResponse("Recovered after overload."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	// Observed: Like 429, a 529 is retried with backoff and the turn
+	// succeeds with the response of the retry.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Recovered after overload."
+		}).Assert("result"),
+	)
+	if got := stub.RequestCount(); got != 2 {
+		t.Errorf("RequestCount() = %d, want 2 (the 529 and its retry)", got)
+	}
+}
+
+// Retry after HTTP 500 internal server error
+func TestHTTPServerErrorRetry(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: internal server error
+		utils.HTTPErrorResponse(500, nil, "api_error", "Internal server error"),
+		// Request 2: the retry succeeds
+		utils.TextResponse("Recovered after server error."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	// Observed: A 5xx is retried with backoff like 429 and 529.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Recovered after server error."
+		}).Assert("result"),
+	)
+	if got := stub.RequestCount(); got != 2 {
+		t.Errorf("RequestCount() = %d, want 2 (the 500 and its retry)", got)
+	}
+}
+
+// Retry after an HTTP 502 whose body is not JSON
+func TestHTTPNonJSONServerErrorRetry(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: a proxy in front of the API answers with an HTML page
+		utils.HTTPRawResponse(502, nil, "<html><body><h1>502 Bad Gateway</h1></body></html>"),
+		// Request 2: the retry succeeds
+		utils.TextResponse("Recovered after bad gateway."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	// Observed: A 5xx without an API error body is retried like any other 5xx.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Recovered after bad gateway."
+		}).Assert("result"),
+	)
+	if got := stub.RequestCount(); got != 2 {
+		t.Errorf("RequestCount() = %d, want 2 (the 502 and its retry)", got)
+	}
+}
+
+// HTTP 401 for an invalid API key
+func TestHTTPAuthenticationError(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Every request is rejected.
+		utils.HTTPErrorResponse(401, nil, "authentication_error", "invalid x-api-key"),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	// CLAUDE_CODE_MAX_RETRIES=0 keeps the test from waiting on retries.
+	s := utils.NewSessionWithEnv(t, stub.URL(), []string{"CLAUDE_CODE_MAX_RETRIES=0"})
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	// Observed: The CLI reports the error as an assistant message of the
+	// model "<synthetic>" whose text is a user-facing error message, and ends
+	// the turn with subtype "success", is_error:true, and that text as result.
+	// No errors array is emitted.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Model = "<synthetic>"
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "Invalid API key · Fix external API key",
+				},
+			}
+		}).Assert("message.model").Ignore("message.stop_reason", "message.stop_sequence"),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.IsError = true
+			m.Result = "Invalid API key · Fix external API key"
+		}).Assert("result"),
+	)
+}
+
+// HTTP 413 for a request that is too large
+func TestHTTPPromptTooLong(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		utils.HTTPErrorResponse(413, nil, "request_too_large", "Prompt is too long"),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	// Observed: A 413 is not retried. Like 401, it is reported as a
+	// "<synthetic>" assistant message and a result with is_error:true whose
+	// result is the error message.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Model = "<synthetic>"
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "Prompt is too long",
+				},
+			}
+		}).Assert("message.model").Ignore("message.stop_reason", "message.stop_sequence"),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.IsError = true
+			m.Result = "Prompt is too long"
+		}).Assert("result"),
+	)
+	if got := stub.RequestCount(); got != 1 {
+		t.Errorf("RequestCount() = %d, want 1 (no retry)", got)
+	}
+}
+
+// Stream cut off after a content_block_delta
+func TestStreamCutMidBlock(t *testing.T) {
+	t.Parallel()
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		// Request 1: message_start, content_block_start, content_block_delta,
+		// then the connection drops.
+		utils.Truncate(utils.TextResponse("This stream is cut."), 3),
+		// Request 2: the retry succeeds
+		utils.TextResponse("Recovered after a cut stream."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	output := s.Read()
+	// Observed: The CLI discards the partial message and retries the request
+	// like a 5xx. The text streamed before the cut is never emitted.
+	utils.AssertOutput(t, output,
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "Recovered after a cut stream.",
+				},
+			}
+		}),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Recovered after a cut stream."
+		}).Assert("result"),
+	)
+	for i, raw := range output {
+		if strings.Contains(string(raw), "This stream is cut.") {
+			t.Errorf("output[%d] contains the text of the cut stream: %s", i, raw)
+		}
+	}
+	if got := stub.RequestCount(); got != 2 {
+		t.Errorf("RequestCount() = %d, want 2 (the cut stream and its retry)", got)
+	}
+}
+
+// Malformed data: line in the stream
+func TestStreamMalformedData(t *testing.T) {
+	t.Parallel()
+	events := utils.TextResponse("This stream is malformed.")
+	// Replace the content_block_delta with a data: line that is not JSON.
+	events[2] = utils.SSEEvent{Raw: "event: content_block_delta\ndata: {not json\n\n"}
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		events,
+		utils.TextResponse("Not reached."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSession(t, stub.URL())
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	output := s.Read()
+	// Observed: A parse error is not retried. The CLI reports it as a
+	// "<synthetic>" assistant message whose text starts with "API Error: "
+	// followed by the parser message, and ends the turn with subtype
+	// "success", is_error:true, and that text as result.
+	utils.AssertOutput(t, output,
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Model = "<synthetic>"
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "API Error: JSON Parse error",
+				},
+			}
+		}).Assert("message.model").Ignore("message.stop_reason", "message.stop_sequence", "message.content.*.text"),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.IsError = true
+			m.Result = "API Error: JSON Parse error"
+		}),
+	)
+	result, err := DecodeMessage(output[len(output)-1], Lenient())
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if r, ok := result.(*ResultSuccessMessage); !ok || !strings.HasPrefix(r.Result, "API Error: ") {
+		t.Errorf("result = %+v, want a result starting with %q", result, "API Error: ")
+	}
+	if got := stub.RequestCount(); got != 1 {
+		t.Errorf("RequestCount() = %d, want 1 (no retry)", got)
+	}
+}
+
+// Request timeout before the first event
+func TestStreamTimeoutBeforeFirstEvent(t *testing.T) {
+	t.Parallel()
+	// Nothing, not even the response headers, is sent until the test
+	// releases the stall.
+	release := make(chan struct{})
+	stalled := append([]utils.SSEEvent{{Stall: true, Release: release}}, utils.TextResponse("Too late.")...)
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		stalled,
+		utils.TextResponse("Recovered after a timeout."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSessionWithEnv(t, stub.URL(), []string{"API_TIMEOUT_MS=1000"})
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	select {
+	case <-stub.Stalled():
+	case <-time.After(30 * time.Second):
+		t.Fatal("API response did not stall")
+	}
+	// Observed: The request times out after API_TIMEOUT_MS and is retried;
+	// the turn succeeds with the response of the retry while the first
+	// response is still stalled.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Recovered after a timeout."
+		}).Assert("result"),
+	)
+	close(release)
+	if got := stub.RequestCount(); got != 2 {
+		t.Errorf("RequestCount() = %d, want 2 (the timed out request and its retry)", got)
+	}
+}
+
+// Slow stream after the first event
+func TestStreamSlowEvents(t *testing.T) {
+	t.Parallel()
+	events := utils.TextResponse("Slow but complete.")
+	// Stall after the content_block_start until the test releases it, and
+	// pace the other events.
+	release := make(chan struct{})
+	slow := utils.Delayed(slices.Concat(events[:2], []utils.SSEEvent{{Stall: true, Release: release}}, events[2:]), 100*time.Millisecond)
+	stub := &utils.StubAPIServer{Responses: [][]utils.SSEEvent{
+		slow,
+		utils.TextResponse("Not reached."),
+	}}
+	stub.Start()
+	defer stub.Close()
+
+	s := utils.NewSessionWithEnv(t, stub.URL(), []string{"API_TIMEOUT_MS=1000"})
+	defer s.Close()
+
+	s.Send(utils.MustJSON(UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	}))
+	select {
+	case <-stub.Stalled():
+	case <-time.After(30 * time.Second):
+		t.Fatal("API response did not stall")
+	}
+	// Hold the started stream for longer than API_TIMEOUT_MS.
+	time.Sleep(3 * time.Second)
+	if got := stub.RequestCount(); got != 1 {
+		t.Fatalf("RequestCount() = %d during the pause, want 1 (no retry)", got)
+	}
+	close(release)
+	// Observed: Once the stream has started, API_TIMEOUT_MS no longer
+	// applies. The CLI waits for the pause and completes the turn without
+	// retrying.
+	utils.AssertOutput(t, s.Read(),
+		defaultInitPattern(),
+		defaultAssistantPattern(func(m *AssistantMessage) {
+			m.Message.Content = []IsContentBlock{
+				TextBlock{
+					ContentBlockBase: ContentBlockBase{Type: BlockText},
+					Text:             "Slow but complete.",
+				},
+			}
+		}),
+		defaultResultPattern(func(m *ResultSuccessMessage) {
+			m.Result = "Slow but complete."
+		}).Assert("result"),
+	)
+	if got := stub.RequestCount(); got != 1 {
+		t.Errorf("RequestCount() = %d, want 1 (no retry)", got)
+	}
+}
@@ -43,7 +43,7 @@ func TestStopSequence(t *testing.T) {
 				},
 			}
 		}),
-		// The result message also has stop_reason:null (StopReason omitted → zero value).
+		// The result message also has stop_reason:null (StopReason zero value → null).
 		defaultResultPattern(func(m *ResultSuccessMessage) { m.Result = "Hello" }).Assert("result"),
 	)
 }
@@ -0,0 +1,500 @@
+// Package client drives the Claude Code CLI over the stream-json protocol.
+//
+// A Client starts the CLI as a subprocess, writes input messages to its stdin
+// and reads typed output messages from its stdout:
+//
+//	c, err := client.Start(ctx, client.Options{Model: "sonnet"})
+//	if err != nil { ... }
+//	defer c.Close()
+//	err = c.Send(ctx, &ccprotocol.UserTextMessage{...})
+//	msg, err := c.Recv(ctx)
+//
+// Control requests are correlated by the Client's Controller: handlers
+// registered with c.Controller().Handle answer requests from the CLI, and
+// c.Controller().Request waits for responses that Recv consumes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	ccprotocol "github.com/hrntknr/claudecodeprotocol"
+)
+
+// DefaultCloseTimeout is how long Close waits for the CLI to exit after
+// stdin is closed before killing its process group.
+const DefaultCloseTimeout = 10 * time.Second
+
+// Options configures the CLI process started by Start.
+// Zero values leave the corresponding CLI flag unset unless noted.
+type Options struct {
+	Path string   // CLI executable (default "claude")
+	Dir  string   // Working directory (default: current directory)
+	Env  []string // Extra "KEY=VALUE" entries appended to the process environment
+
+	InputFormat  string // --input-format (default "stream-json")
+	OutputFormat string // --output-format (default "stream-json"); stream-json also adds --verbose
+
+	PermissionPromptTool       string                    // --permission-prompt-tool (e.g. "stdio")
+	PermissionMode             ccprotocol.PermissionMode // --permission-mode
+	DangerouslySkipPermissions bool                      // --dangerously-skip-permissions
+	Model                      string                    // --model
+	MaxTurns                   int                       // --max-turns
+	SessionID                  string                    // --session-id
+	NoSessionPersistence       bool                      // --no-session-persistence
+	Args                       []string                  // Additional flags appended after the above
+
+	// PermissionPolicy answers can_use_tool requests via the Controller. It
+	// requires PermissionPromptTool "stdio", which is used when
+	// PermissionPromptTool is empty. Requests are evaluated in the client's
+	// current permission mode (see Client.PermissionMode); the policy itself
+	// is not modified, so it may be shared by several clients.
+	PermissionPolicy *ccprotocol.PermissionPolicy
+
+	DecodeOptions  []ccprotocol.DecodeOption // Options passed to DecodeMessage by Recv
+	CloseTimeout   time.Duration             // Grace period for Close (default DefaultCloseTimeout)
+	ControlTimeout time.Duration             // Controller.Timeout (default ccprotocol.DefaultControlTimeout)
+}
+
+// args returns the CLI arguments for o.
+func (o Options) args() []string {
+	input := o.InputFormat
+	if input == "" {
+		input = "stream-json"
+	}
+	output := o.OutputFormat
+	if output == "" {
+		output = "stream-json"
+	}
+	args := []string{"--input-format", input, "--output-format", output}
+	if output == "stream-json" {
+		args = append(args, "--verbose")
+	}
+	promptTool := o.PermissionPromptTool
+	if promptTool == "" && o.PermissionPolicy != nil {
+		promptTool = "stdio"
+	}
+	if promptTool != "" {
+		args = append(args, "--permission-prompt-tool", promptTool)
+	}
+	if o.PermissionMode != "" {
+		args = append(args, "--permission-mode", string(o.PermissionMode))
+	}
+	if o.DangerouslySkipPermissions {
+		args = append(args, "--dangerously-skip-permissions")
+	}
+	if o.Model != "" {
+		args = append(args, "--model", o.Model)
+	}
+	if o.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(o.MaxTurns))
+	}
+	if o.SessionID != "" {
+		args = append(args, "--session-id", o.SessionID)
+	}
+	if o.NoSessionPersistence {
+		args = append(args, "--no-session-persistence")
+	}
+	return append(args, o.Args...)
+}
+
+// Client is a running CLI process. Send and Recv may be called from
+// different goroutines.
+type Client struct {
+	cmd          *exec.Cmd
+	stdin        *os.File
+	stdout       io.ReadCloser
+	writer       *ccprotocol.Writer
+	writeMu      sync.Mutex
+	control      *ccprotocol.Controller
+	stderr       *syncBuffer
+	lines        chan line
+	readDone     chan struct{}
+	closing      chan struct{}
+	decodeOpts   []ccprotocol.DecodeOption
+	closeTimeout time.Duration
+
+	modeMu sync.Mutex
+	mode   ccprotocol.PermissionMode
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// line is a single stdout line (or the read error that ended the stream).
+type line struct {
+	raw json.RawMessage
+	num int
+	err error
+}
+
+// Start starts the CLI with the given options. ctx only bounds process
+// startup; use Close to stop the process.
+func Start(ctx context.Context, opts Options) (*Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path := opts.Path
+	if path == "" {
+		path = "claude"
+	}
+	cmd := exec.Command(path, opts.args()...)
+	cmd.Dir = opts.Dir
+	cmd.Env = append(cmd.Environ(), opts.Env...)
+	// Create a new process group so we can kill the entire group
+	// (including any teammate subprocesses) during cleanup.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// The write end of stdin is an *os.File rather than cmd.StdinPipe so
+	// that a pending write can be cut short with a deadline.
+	stdinR, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin pipe: %w", err)
+	}
+	cmd.Stdin = stdinR
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdinR.Close()
+		stdin.Close()
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	stderr := &syncBuffer{}
+	cmd.Stderr = stderr
+
+	err = cmd.Start()
+	stdinR.Close() // the child holds its own copy
+	if err != nil {
+		stdin.Close()
+		return nil, fmt.Errorf("start %s: %w", path, err)
+	}
+
+	closeTimeout := opts.CloseTimeout
+	if closeTimeout <= 0 {
+		closeTimeout = DefaultCloseTimeout
+	}
+	c := &Client{
+		cmd:          cmd,
+		stdin:        stdin,
+		stdout:       stdout,
+		writer:       ccprotocol.NewWriter(stdin),
+		stderr:       stderr,
+		lines:        make(chan line, 64),
+		readDone:     make(chan struct{}),
+		closing:      make(chan struct{}),
+		decodeOpts:   opts.DecodeOptions,
+		closeTimeout: closeTimeout,
+		mode:         opts.PermissionMode,
+	}
+	if c.mode == "" {
+		c.mode = ccprotocol.PermissionDefault
+	}
+	c.control = ccprotocol.NewController(c.Send)
+	c.control.Timeout = opts.ControlTimeout
+	if p := opts.PermissionPolicy; p != nil {
+		c.control.Handle(ccprotocol.ControlCanUseTool, p.ModeHandler(c.PermissionMode))
+	}
+	go c.readLoop(ccprotocol.NewReader(stdout))
+	return c, nil
+}
+
+// readLoop forwards stdout lines to c.lines until the stream ends. Once the
+// client is closing, lines nobody will receive are discarded, so the CLI
+// never blocks on a full stdout pipe while it shuts down.
+func (c *Client) readLoop(r *ccprotocol.Reader) {
+	defer close(c.readDone)
+	defer close(c.lines)
+	for {
+		raw, err := r.ReadRaw()
+		if err == io.EOF {
+			return
+		}
+		select {
+		case c.lines <- line{raw: raw, num: r.Line(), err: err}:
+		case <-c.closing:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Send writes an input message (user, control_request or control_response)
+// to the CLI's stdin. The write blocks while the stdin pipe is full. If ctx
+// is done first, the write is abandoned and ctx.Err() is returned; the line
+// may then have been written in part, which leaves stdin unusable, so close
+// the client.
+func (c *Client) Send(ctx context.Context, m ccprotocol.IsMessage) error {
+	return c.write(ctx, func() error { return c.writer.Write(m) })
+}
+
+// SendRaw writes a pre-encoded JSON line to the CLI's stdin.
+func (c *Client) SendRaw(ctx context.Context, raw []byte) error {
+	return c.write(ctx, func() error { return c.writer.WriteRaw(raw) })
+}
+
+// write runs fn, which writes to stdin, and cuts it short with a write
+// deadline when ctx is done. Writes are serialized so that the deadline of
+// one cannot affect another.
+func (c *Client) write(ctx context.Context, fn func() error) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	expired := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(expired)
+		c.stdin.SetWriteDeadline(time.Unix(1, 0))
+	})
+	err := fn()
+	if !stop() {
+		// The deadline has been set; clear it for the next write.
+		<-expired
+		c.stdin.SetWriteDeadline(time.Time{})
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// Recv returns the next message from the CLI's stdout, decoded with
+// DecodeMessage. Messages consumed by the Controller (responses to its
+// requests and requests with a registered handler) are not returned.
+// Decode errors are returned as *ccprotocol.LineError and do not end the
+// stream. It returns io.EOF once stdout is closed.
+func (c *Client) Recv(ctx context.Context) (ccprotocol.IsMessage, error) {
+	return c.recv(ctx, nil)
+}
+
+// recv is Recv; answered, when non-nil, is called with each control request
+// the Controller consumes.
+func (c *Client) recv(ctx context.Context, answered func(*ccprotocol.ControlRequestMessage)) (ccprotocol.IsMessage, error) {
+	for {
+		l, err := c.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := ccprotocol.DecodeMessage(l.raw, c.decodeOpts...)
+		if err != nil {
+			return nil, &ccprotocol.LineError{Line: l.num, Err: err}
+		}
+		if !c.control.Dispatch(msg) {
+			return msg, nil
+		}
+		if m, ok := msg.(*ccprotocol.ControlRequestMessage); ok && answered != nil {
+			answered(m)
+		}
+	}
+}
+
+// RecvTurn receives messages until a result message ends the turn and
+// returns them aggregated. Control requests answered by the Controller, such
+// as can_use_tool requests decided by a PermissionPolicy, are part of the
+// turn even though Recv does not return them. On error it returns the
+// partial turn along with the error; io.EOF before the result is returned as
+// io.ErrUnexpectedEOF.
+func (c *Client) RecvTurn(ctx context.Context) (*ccprotocol.Turn, error) {
+	t := &ccprotocol.Turn{}
+	answered := func(m *ccprotocol.ControlRequestMessage) { t.Add(m) }
+	for {
+		m, err := c.recv(ctx, answered)
+		if err == io.EOF {
+			return t, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return t, err
+		}
+		if t.Add(m) {
+			return t, nil
+		}
+	}
+}
+
+// RecvRaw returns the next line from the CLI's stdout without decoding it.
+// The line is not passed to the Controller. It returns io.EOF once stdout is
+// closed.
+func (c *Client) RecvRaw(ctx context.Context) (json.RawMessage, error) {
+	l, err := c.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return l.raw, nil
+}
+
+func (c *Client) next(ctx context.Context) (line, error) {
+	select {
+	case <-ctx.Done():
+		return line{}, ctx.Err()
+	case l, ok := <-c.lines:
+		if !ok {
+			return line{}, io.EOF
+		}
+		if l.err != nil {
+			return line{}, fmt.Errorf("read stdout: %w", l.err)
+		}
+		c.trackMode(l.raw)
+		return l, nil
+	}
+}
+
+// trackMode updates the permission mode from a system message reporting it
+// (system/init and system/status).
+func (c *Client) trackMode(raw json.RawMessage) {
+	if !bytes.Contains(raw, []byte(`"permissionMode"`)) {
+		return
+	}
+	var m struct {
+		Type           ccprotocol.MessageType    `json:"type"`
+		PermissionMode ccprotocol.PermissionMode `json:"permissionMode"`
+	}
+	if json.Unmarshal(raw, &m) != nil || m.Type != ccprotocol.TypeSystem || m.PermissionMode == "" {
+		return
+	}
+	c.setPermissionMode(m.PermissionMode)
+}
+
+// PermissionMode returns the session's permission mode: Options.PermissionMode
+// at first, then the last mode reported by the CLI in a system message or
+// set with SetPermissionMode.
+func (c *Client) PermissionMode() ccprotocol.PermissionMode {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	return c.mode
+}
+
+func (c *Client) setPermissionMode(mode ccprotocol.PermissionMode) {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	c.mode = mode
+}
+
+// SetPermissionMode sends a set_permission_mode control request and, once the
+// CLI accepts it, switches the mode PermissionPolicy requests are evaluated
+// in. Like Controller().Request it needs another goroutine receiving.
+func (c *Client) SetPermissionMode(ctx context.Context, mode ccprotocol.PermissionMode) error {
+	_, err := c.control.Request(ctx, ccprotocol.SetPermissionModeRequest{
+		ControlRequestBase: ccprotocol.ControlRequestBase{Subtype: ccprotocol.ControlSetPermissionMode},
+		Mode:               mode,
+	})
+	if err != nil {
+		return fmt.Errorf("set permission mode: %w", err)
+	}
+	c.setPermissionMode(mode)
+	return nil
+}
+
+// Interrupt sends an interrupt control request, which aborts the turn in
+// progress, and receives the rest of that turn. The CLI answers the request
+// before it ends the turn with a result, typically of subtype
+// error_during_execution. Interrupt reads with Recv, so it must not be called
+// while another goroutine is receiving; in that case send the request with
+// Controller().Request instead.
+func (c *Client) Interrupt(ctx context.Context) (*ccprotocol.Turn, error) {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.control.Request(ctx, ccprotocol.InterruptRequest{
+			ControlRequestBase: ccprotocol.ControlRequestBase{Subtype: ccprotocol.ControlInterrupt},
+		})
+		errc <- err
+	}()
+	t, err := c.RecvTurn(ctx)
+	if err != nil {
+		return t, err
+	}
+	if err := <-errc; err != nil {
+		return t, fmt.Errorf("interrupt: %w", err)
+	}
+	return t, nil
+}
+
+// Controller returns the Controller correlating control messages on this
+// session.
+func (c *Client) Controller() *ccprotocol.Controller {
+	return c.control
+}
+
+// Stderr returns everything the CLI has written to stderr so far.
+func (c *Client) Stderr() string {
+	return c.stderr.String()
+}
+
+// Close closes stdin, reads stdout to the end and waits for the CLI process
+// to exit. Output not yet received is discarded. If the process does not
+// exit within the close timeout (e.g. due to running teammate subprocesses),
+// the whole process group is killed via SIGKILL and an error reporting the
+// kill is returned. The Controller is closed first. Close is idempotent.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.control.Close()
+		close(c.closing)
+		c.stdin.Close()
+
+		deadline := time.After(c.closeTimeout)
+		killed := false
+		kill := func() {
+			// Kill the entire process group to clean up child processes (teammates).
+			_ = syscall.Kill(-c.cmd.Process.Pid, syscall.SIGKILL)
+			// A child that left the group may still hold stdout open.
+			c.stdout.Close()
+			killed = true
+		}
+
+		// Wait must not run before stdout has been read to the end.
+		select {
+		case <-c.readDone:
+		case <-deadline:
+			kill()
+			<-c.readDone
+		}
+		done := make(chan error, 1)
+		go func() { done <- c.cmd.Wait() }()
+
+		var err error
+		if killed {
+			err = <-done
+		} else {
+			select {
+			case err = <-done:
+			case <-deadline:
+				kill()
+				err = <-done
+			}
+		}
+		switch {
+		case killed:
+			c.closeErr = fmt.Errorf("CLI did not exit within %s, killed process group", c.closeTimeout)
+		case err != nil:
+			c.closeErr = fmt.Errorf("CLI exit: %w", err)
+		}
+	})
+	return c.closeErr
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes and reads.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
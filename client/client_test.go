@@ -0,0 +1,376 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ccprotocol "github.com/hrntknr/claudecodeprotocol"
+	"github.com/hrntknr/claudecodeprotocol/client"
+)
+
+// fakeCLI writes a shell script standing in for the claude executable and
+// returns its path.
+func fakeCLI(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatalf("write fake CLI: %v", err)
+	}
+	return path
+}
+
+func start(t *testing.T, opts client.Options) *client.Client {
+	t.Helper()
+	c, err := client.Start(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_SendRecv(t *testing.T) {
+	c := start(t, client.Options{Path: fakeCLI(t, "exec cat")})
+	ctx := context.Background()
+
+	err := c.Send(ctx, &ccprotocol.UserTextMessage{
+		MessageBase: ccprotocol.MessageBase{Type: ccprotocol.TypeUser},
+		Message:     ccprotocol.UserTextBody{Role: ccprotocol.RoleUser, Content: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	msg, err := c.Recv(ctx)
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	m, ok := msg.(*ccprotocol.UserTextMessage)
+	if !ok {
+		t.Fatalf("expected *UserTextMessage, got %T", msg)
+	}
+	if m.Message.Content != "hello" {
+		t.Errorf("Content = %q, want %q", m.Message.Content, "hello")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	if _, err := c.Recv(ctx); err != io.EOF {
+		t.Errorf("Recv after Close = %v, want io.EOF", err)
+	}
+}
+
+func TestClient_Args(t *testing.T) {
+	// The fake CLI echoes its arguments back as a user message.
+	c := start(t, client.Options{
+		Path:                 fakeCLI(t, `printf '{"type":"user","message":{"role":"user","content":"%s"}}\n' "$*"`),
+		PermissionPromptTool: "stdio",
+		PermissionMode:       ccprotocol.PermissionPlan,
+		Model:                "sonnet",
+		MaxTurns:             3,
+		SessionID:            "00000000-0000-0000-0000-000000000001",
+		Args:                 []string{"--debug"},
+	})
+	msg, err := c.Recv(context.Background())
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	got := msg.(*ccprotocol.UserTextMessage).Message.Content
+	want := strings.Join([]string{
+		"--input-format stream-json",
+		"--output-format stream-json",
+		"--verbose",
+		"--permission-prompt-tool stdio",
+		"--permission-mode plan",
+		"--model sonnet",
+		"--max-turns 3",
+		"--session-id 00000000-0000-0000-0000-000000000001",
+		"--debug",
+	}, " ")
+	if got != want {
+		t.Errorf("args = %q\n  want %q", got, want)
+	}
+}
+
+func TestClient_RecvContext(t *testing.T) {
+	c := start(t, client.Options{Path: fakeCLI(t, "exec cat")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Recv(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Recv = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClient_RecvLineError(t *testing.T) {
+	c := start(t, client.Options{Path: fakeCLI(t, `echo '{"type":"unknown_type"}'; echo '{"type":"user","message":{"role":"user","content":"ok"}}'`)})
+	ctx := context.Background()
+
+	_, err := c.Recv(ctx)
+	var lineErr *ccprotocol.LineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 1 {
+		t.Fatalf("Recv = %v, want *LineError on line 1", err)
+	}
+	// A decode error does not end the stream.
+	if _, err := c.Recv(ctx); err != nil {
+		t.Errorf("Recv after decode error: %v", err)
+	}
+}
+
+func TestClient_SendContext(t *testing.T) {
+	// The fake CLI never reads stdin, so a line larger than the pipe
+	// buffer blocks until the context ends.
+	c := start(t, client.Options{Path: fakeCLI(t, "exec sleep 60"), CloseTimeout: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.Send(ctx, &ccprotocol.UserTextMessage{
+		MessageBase: ccprotocol.MessageBase{Type: ccprotocol.TypeUser},
+		Message:     ccprotocol.UserTextBody{Role: ccprotocol.RoleUser, Content: strings.Repeat("x", 1<<20)},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClient_CloseDrainsStdout(t *testing.T) {
+	// The fake CLI writes more output than the pipe and the receive buffer
+	// hold; Close must read it so the CLI can exit.
+	c := start(t, client.Options{
+		Path: fakeCLI(t, `yes '{"type":"user","message":{"role":"user","content":"ok"}}' | head -n 5000; read line || :`),
+	})
+	if err := c.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
+
+func TestClient_CloseKillsProcessGroup(t *testing.T) {
+	// The fake CLI ignores stdin EOF and leaves a child running.
+	c := start(t, client.Options{
+		Path:         fakeCLI(t, "sleep 60 & wait"),
+		CloseTimeout: 100 * time.Millisecond,
+	})
+
+	begin := time.Now()
+	if err := c.Close(); err == nil {
+		t.Error("expected error reporting the kill, got nil")
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("Close took %s", elapsed)
+	}
+}
+
+func TestClient_RecvTurn(t *testing.T) {
+	c := start(t, client.Options{Path: fakeCLI(t, `cat <<'EOF'
+{"type":"assistant","message":{"content":[{"type":"text","text":"Hello!"}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u1"}
+{"type":"result","subtype":"success","is_error":false,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"Hello!","stop_reason":null,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"u2"}
+EOF`)})
+
+	turn, err := c.RecvTurn(context.Background())
+	if err != nil {
+		t.Fatalf("recv turn: %v", err)
+	}
+	if got := turn.Text(); got != "Hello!" {
+		t.Errorf("Text() = %q, want %q", got, "Hello!")
+	}
+	if _, ok := turn.Result.(*ccprotocol.ResultSuccessMessage); !ok {
+		t.Errorf("Result type = %T, want *ResultSuccessMessage", turn.Result)
+	}
+}
+
+func TestClient_ControllerRequest(t *testing.T) {
+	// The fake CLI answers the first control_request with its request_id.
+	c := start(t, client.Options{Path: fakeCLI(t, `read line
+id=$(printf '%s' "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"user","message":{"role":"user","content":"before"}}'
+printf '{"type":"control_response","response":{"subtype":"success","request_id":"%s","response":{"mode":"plan"}}}\n' "$id"
+echo '{"type":"user","message":{"role":"user","content":"after"}}'`)})
+	ctx := context.Background()
+
+	// Recv must run for the response to reach the Controller.
+	msgs := make(chan ccprotocol.IsMessage, 2)
+	go func() {
+		for {
+			m, err := c.Recv(ctx)
+			if err != nil {
+				close(msgs)
+				return
+			}
+			msgs <- m
+		}
+	}()
+
+	resp, err := c.Controller().Request(ctx, ccprotocol.SetPermissionModeRequest{
+		ControlRequestBase: ccprotocol.ControlRequestBase{Subtype: ccprotocol.ControlSetPermissionMode},
+		Mode:               ccprotocol.PermissionPlan,
+	})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if r, ok := resp.(*ccprotocol.SetPermissionModeResponse); !ok || r.Mode != ccprotocol.PermissionPlan {
+		t.Errorf("response = %#v, want mode plan", resp)
+	}
+
+	// The control_response was consumed; the other messages were not.
+	var got []string
+	for m := range msgs {
+		got = append(got, m.(*ccprotocol.UserTextMessage).Message.Content)
+	}
+	if strings.Join(got, ",") != "before,after" {
+		t.Errorf("Recv returned %v, want [before after]", got)
+	}
+}
+
+func TestClient_PermissionPolicy(t *testing.T) {
+	// The fake CLI asks to run Bash and reports the behavior it was sent.
+	policy := &ccprotocol.PermissionPolicy{
+		Rules: []ccprotocol.PermissionRule{{Name: "no-bash", Tool: ccprotocol.ToolBash, Action: ccprotocol.ActionDeny}},
+	}
+	c := start(t, client.Options{
+		Path: fakeCLI(t, `case "$*" in *"--permission-prompt-tool stdio"*) ;; *) exit 1 ;; esac
+echo '{"type":"control_request","request_id":"r1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"ls"},"tool_use_id":"toolu_001"}}'
+read line
+case "$line" in *'"behavior":"deny"'*) b=deny ;; *) b=allow ;; esac
+echo '{"type":"user","message":{"role":"user","content":"'$b'"}}'`),
+		PermissionPolicy: policy,
+	})
+
+	msg, err := c.Recv(context.Background())
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	if got := msg.(*ccprotocol.UserTextMessage).Message.Content; got != "deny" {
+		t.Errorf("CLI received behavior %q, want deny", got)
+	}
+	d := policy.Decisions()
+	if len(d) != 1 || d[0].Rule != "no-bash" || d[0].Mode != ccprotocol.PermissionDefault {
+		t.Errorf("Decisions() = %+v", d)
+	}
+}
+
+func TestClient_RecvTurnPermissionRequests(t *testing.T) {
+	// A request answered by the policy is not returned by Recv but is part
+	// of the turn.
+	policy := &ccprotocol.PermissionPolicy{Default: ccprotocol.ActionAllow}
+	c := start(t, client.Options{
+		Path: fakeCLI(t, `echo '{"type":"control_request","request_id":"r1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"ls"},"tool_use_id":"toolu_001"}}'
+read line
+echo '{"type":"result","subtype":"success","is_error":false,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"done","stop_reason":null,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"u2"}'`),
+		PermissionPolicy: policy,
+	})
+
+	turn, err := c.RecvTurn(context.Background())
+	if err != nil {
+		t.Fatalf("recv turn: %v", err)
+	}
+	if len(turn.PermissionRequests) != 1 || turn.PermissionRequests[0].RequestID != "r1" {
+		t.Errorf("PermissionRequests = %+v, want request r1", turn.PermissionRequests)
+	}
+	if len(policy.Decisions()) != 1 {
+		t.Errorf("Decisions() = %+v, want one", policy.Decisions())
+	}
+}
+
+func TestClient_PermissionPolicyMode(t *testing.T) {
+	// One policy serves two clients, each evaluated in its own mode: the
+	// first from Options.PermissionMode, the second from system/status.
+	policy := &ccprotocol.PermissionPolicy{
+		Rules: []ccprotocol.PermissionRule{
+			{Name: "plan", Mode: ccprotocol.PermissionPlan, Action: ccprotocol.ActionDeny},
+			{Name: "edits", Mode: ccprotocol.PermissionAcceptEdits, Action: ccprotocol.ActionAllow},
+		},
+	}
+	ask := `echo '{"type":"control_request","request_id":"r1","request":{"subtype":"can_use_tool","tool_name":"Edit","input":{"file_path":"a.go"},"tool_use_id":"toolu_001"}}'
+read line
+echo '{"type":"user","message":{"role":"user","content":"answered"}}'`
+	status := `echo '{"type":"system","subtype":"status","status":null,"permissionMode":"acceptEdits","uuid":"u1","session_id":"abc"}'
+`
+	for _, tt := range []struct {
+		script string
+		mode   ccprotocol.PermissionMode
+		want   ccprotocol.PermissionMode
+	}{
+		{ask, ccprotocol.PermissionPlan, ccprotocol.PermissionPlan},
+		{status + ask, "", ccprotocol.PermissionAcceptEdits},
+	} {
+		c := start(t, client.Options{Path: fakeCLI(t, tt.script), PermissionMode: tt.mode, PermissionPolicy: policy})
+		for {
+			msg, err := c.Recv(context.Background())
+			if err != nil {
+				t.Fatalf("recv: %v", err)
+			}
+			if _, ok := msg.(*ccprotocol.UserTextMessage); ok {
+				break
+			}
+		}
+		if got := c.PermissionMode(); got != tt.want {
+			t.Errorf("PermissionMode() = %q, want %q", got, tt.want)
+		}
+	}
+
+	d := policy.Decisions()
+	if len(d) != 2 || d[0].Rule != "plan" || d[1].Rule != "edits" {
+		t.Errorf("Decisions() = %+v", d)
+	}
+}
+
+func TestClient_SetPermissionMode(t *testing.T) {
+	// The fake CLI accepts the first control_request.
+	c := start(t, client.Options{Path: fakeCLI(t, `read line
+case "$line" in *'"subtype":"set_permission_mode"'*) ;; *) exit 1 ;; esac
+id=$(printf '%s' "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+printf '{"type":"control_response","response":{"subtype":"success","request_id":"%s","response":{"mode":"plan"}}}\n' "$id"`)})
+	ctx := context.Background()
+
+	// Recv must run for the response to reach the Controller.
+	go func() {
+		for {
+			if _, err := c.Recv(ctx); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := c.SetPermissionMode(ctx, ccprotocol.PermissionPlan); err != nil {
+		t.Fatalf("set permission mode: %v", err)
+	}
+	if got := c.PermissionMode(); got != ccprotocol.PermissionPlan {
+		t.Errorf("PermissionMode() = %q, want %q", got, ccprotocol.PermissionPlan)
+	}
+}
+
+func TestClient_Interrupt(t *testing.T) {
+	// The fake CLI answers the interrupt, reports it in a user message, then
+	// ends the turn.
+	c := start(t, client.Options{Path: fakeCLI(t, `read line
+case "$line" in *'"subtype":"interrupt"'*) ;; *) exit 1 ;; esac
+id=$(printf '%s' "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+printf '{"type":"control_response","response":{"subtype":"success","request_id":"%s","response":{"still_queued":[]}}}\n' "$id"
+echo '{"type":"user","message":{"role":"user","content":[{"type":"text","text":"[Request interrupted by user]"}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"u0"}'
+echo '{"type":"result","subtype":"error_during_execution","is_error":true,"duration_ms":10,"duration_api_ms":0,"num_turns":1,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"u1","errors":[]}'`)})
+
+	turn, err := c.Interrupt(context.Background())
+	if err != nil {
+		t.Fatalf("interrupt: %v", err)
+	}
+	if _, ok := turn.Result.(*ccprotocol.ResultErrorMessage); !ok {
+		t.Errorf("Result type = %T, want *ResultErrorMessage", turn.Result)
+	}
+	// The control_response was consumed by the Controller.
+	if len(turn.Messages) != 2 {
+		t.Fatalf("len(Messages) = %d, want 2", len(turn.Messages))
+	}
+	m, ok := turn.Messages[0].(*ccprotocol.UserContentMessage)
+	if !ok {
+		t.Fatalf("Messages[0] type = %T, want *UserContentMessage", turn.Messages[0])
+	}
+	if tb, ok := m.Message.Content[0].(ccprotocol.TextBlock); !ok || tb.Text != "[Request interrupted by user]" {
+		t.Errorf("Messages[0] content = %#v, want the interrupt text block", m.Message.Content)
+	}
+}
@@ -389,6 +389,8 @@ type sourceTurn struct {
 // found, at which point a turn is created with all accumulated inputs. If an
 // AssertOutput has no preceding Send (e.g. Phase 3 of a permission prompt test),
 // a turn with empty inputs is created to preserve the output patterns.
+// Output read with s.Interrupt() adds the interrupt control_request it sends
+// to the inputs.
 func extractSourceTurns(fset *token.FileSet, fn *ast.FuncDecl) []sourceTurn {
 	var pendingInputs []string
 	var turns []sourceTurn
@@ -404,6 +406,9 @@ func extractSourceTurns(fset *token.FileSet, fn *ast.FuncDecl) []sourceTurn {
 			return true
 		}
 		if isAssertOutputCall(call) && len(call.Args) >= 3 {
+			if isInterruptCall(call.Args[1]) {
+				pendingInputs = append(pendingInputs, interruptInputSource)
+			}
 			var sources []string
 			for _, arg := range call.Args[2:] {
 				if src, ok := extractPatternSource(fset, arg); ok {
@@ -430,6 +435,20 @@ func isSendCall(call *ast.CallExpr) bool {
 	return ok && sel.Sel.Name == "Send"
 }
 
+// interruptInputSource is the documented input of s.Interrupt(). The real
+// request ID is generated by the Controller.
+const interruptInputSource = `utils.MustJSON(ControlRequestMessage{MessageBase: MessageBase{Type: TypeControlRequest}, RequestID: "request-abc123", Request: InterruptRequest{ControlRequestBase{Subtype: ControlInterrupt}}})`
+
+// isInterruptCall checks if an expression is s.Interrupt().
+func isInterruptCall(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Interrupt"
+}
+
 // isAssertOutputCall checks if a call expression is utils.AssertOutput(...).
 func isAssertOutputCall(call *ast.CallExpr) bool {
 	sel, ok := call.Fun.(*ast.SelectorExpr)
@@ -643,6 +662,8 @@ func evalExpressions(root string, sources []string) []string {
 	return lines
 }
 
+var htmlEscapes = map[string]byte{"u003c": '<', "u003e": '>', "u0026": '&'}
+
 // formatJSON pretty-prints a compact JSON string with 2-space indentation,
 // preserving the original key order. It scans raw bytes directly instead of
 // unmarshal/marshal (which would sort keys).
@@ -668,6 +689,12 @@ func formatJSON(src string) string {
 			continue
 		}
 		if c == '\\' && inString {
+			// Undo the HTML escaping of encoding/json (<, >, &).
+			if r, ok := htmlEscapes[src[i+1:min(i+6, n)]]; ok {
+				buf.WriteByte(r)
+				i += 5
+				continue
+			}
 			buf.WriteByte(c)
 			escaped = true
 			continue
@@ -718,7 +745,8 @@ func writeHeader(buf *strings.Builder) {
 	buf.WriteString("> **Unofficial** protocol reference reconstructed from analysis of Claude Code's `stream-json` input/output.\n\n")
 	buf.WriteString("**Note**\n")
 	buf.WriteString("- This documentation is auto-generated from test cases.\n")
-	buf.WriteString("- Automated tests (CI) are run against the latest 3 versions of Claude Code CLI.\n\n")
+	buf.WriteString("- Automated tests (CI) are run against the latest 3 versions of Claude Code CLI.\n")
+	buf.WriteString("- A JSON Schema of all messages is generated at [schema/protocol.schema.json](schema/protocol.schema.json).\n\n")
 }
 
 // writeIndexTable writes a Scenarios section with links to per-category docs.
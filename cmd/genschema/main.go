@@ -0,0 +1,672 @@
+// cmd/genschema generates a JSON Schema (draft 2020-12) for the protocol
+// types in protocol.go.
+//
+// It parses protocol.go for enum constants and struct types, then writes
+// schema/protocol.schema.json with a definition per type under $defs:
+//
+//   - string enum types (MessageType, PermissionMode, ...) list their constants
+//   - struct types map their JSON fields; fields without omitempty are required
+//   - Nullable[T] fields allow null
+//   - Message, ContentBlock, StreamEvent and ControlRequest are unions of the
+//     variants below, discriminated by const type/subtype properties
+//
+// The document itself validates a single message.
+//
+// Usage:
+//
+//	go run ./cmd/genschema
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// variant is a member of a union, identified by the constants of its
+// discriminator properties.
+type variant struct {
+	typeName string         // e.g. "SystemInitMessage"
+	typ      string         // constant for the "type" property, e.g. "TypeSystem"
+	subtype  string         // constant for the "subtype" property, e.g. "SubtypeInit"
+	consts   map[string]any // other properties with a fixed value
+}
+
+// union is a schema that accepts any of its variants. Every struct that
+// embeds base (other than the Unknown* types) must be a variant.
+type union struct {
+	name        string
+	base        string
+	description string
+	variants    []variant
+}
+
+var unions = []union{
+	{
+		name:        "Message",
+		base:        "MessageBase",
+		description: "A stream-json message, read from stdout or written to stdin.",
+		variants: []variant{
+			{typeName: "SystemInitMessage", typ: "TypeSystem", subtype: "SubtypeInit"},
+			{typeName: "SystemStatusMessage", typ: "TypeSystem", subtype: "SubtypeStatus"},
+			{typeName: "AssistantMessage", typ: "TypeAssistant"},
+			{typeName: "UserTextMessage", typ: "TypeUser"},
+			{typeName: "UserToolResultMessage", typ: "TypeUser"},
+			{typeName: "UserContentMessage", typ: "TypeUser"},
+			{typeName: "UserReplayMessage", typ: "TypeUser", consts: map[string]any{"isReplay": true}},
+			{typeName: "ResultSuccessMessage", typ: "TypeResult", subtype: "SubtypeSuccess"},
+			{typeName: "ResultErrorMessage", typ: "TypeResult", subtype: "SubtypeErrorDuringExecution"},
+			{typeName: "ResultMaxTurnsMessage", typ: "TypeResult", subtype: "SubtypeErrorMaxTurns"},
+			{typeName: "ResultOtherMessage", typ: "TypeResult"}, // any other subtype
+			{typeName: "StreamEventMessage", typ: "TypeStreamEvent"},
+			{typeName: "ControlRequestMessage", typ: "TypeControlRequest"},
+			{typeName: "ControlResponseMessage", typ: "TypeControlResponse"},
+		},
+	},
+	{
+		name:        "ContentBlock",
+		base:        "ContentBlockBase",
+		description: "A content block of an assistant or user message.",
+		variants: []variant{
+			{typeName: "TextBlock", typ: "BlockText"},
+			{typeName: "ToolUseBlock", typ: "BlockToolUse"},
+			{typeName: "ThinkingBlock", typ: "BlockThinking"},
+			{typeName: "ToolResultBlock", typ: "BlockToolResult"},
+		},
+	},
+	{
+		name:        "StreamEvent",
+		base:        "StreamEventBase",
+		description: "The event of a stream_event message.",
+		variants: []variant{
+			{typeName: "MessageStartEvent", typ: "EventMessageStart"},
+			{typeName: "ContentBlockStartEvent", typ: "EventContentBlockStart"},
+			{typeName: "ContentBlockDeltaEvent", typ: "EventContentBlockDelta"},
+			{typeName: "ContentBlockStopEvent", typ: "EventContentBlockStop"},
+			{typeName: "MessageDeltaEvent", typ: "EventMessageDelta"},
+			{typeName: "MessageStopEvent", typ: "EventMessageStop"},
+		},
+	},
+	{
+		name:        "ControlRequest",
+		base:        "ControlRequestBase",
+		description: "The request payload of a control_request message.",
+		variants: []variant{
+			{typeName: "CanUseToolRequest", subtype: "ControlCanUseTool"},
+			{typeName: "SetPermissionModeRequest", subtype: "ControlSetPermissionMode"},
+			{typeName: "SetModelRequest", subtype: "ControlSetModel"},
+			{typeName: "InterruptRequest", subtype: "ControlInterrupt"},
+			{typeName: "SetMaxThinkingTokensRequest", subtype: "ControlSetMaxThinkingTokens"},
+			{typeName: "InitializeRequest", subtype: "ControlInitialize"},
+			{typeName: "HookCallbackRequest", subtype: "ControlHookCallback"},
+		},
+	},
+}
+
+// interfaceUnions maps the interface types of protocol.go to their unions.
+var interfaceUnions = map[string]string{
+	"IsMessage":        "Message",
+	"IsContentBlock":   "ContentBlock",
+	"IsStreamEvent":    "StreamEvent",
+	"IsControlRequest": "ControlRequest",
+}
+
+func main() {
+	root := findProjectRoot()
+
+	p, err := parseProtocol(filepath.Join(root, "protocol.go"))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	doc, err := p.document()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	data, err := marshalIndent(doc)
+	if err != nil {
+		fatalf("marshal schema: %v", err)
+	}
+
+	outDir := filepath.Join(root, "schema")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fatalf("error creating schema dir: %v", err)
+	}
+	outPath := filepath.Join(outDir, "protocol.schema.json")
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
+		fatalf("error writing %s: %v", outPath, err)
+	}
+	fmt.Printf("Generated %s\n", outPath)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func findProjectRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		fatalf("cannot get working directory: %v", err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			fatalf("go.mod not found")
+		}
+		dir = parent
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Schema model
+// ---------------------------------------------------------------------------
+
+// schema is a JSON Schema. The zero value accepts any JSON value.
+type schema struct {
+	Ref                  string            `json:"$ref,omitempty"`
+	Title                string            `json:"title,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	Type                 any               `json:"type,omitempty"` // string or []string
+	Const                any               `json:"const,omitempty"`
+	Enum                 []string          `json:"enum,omitempty"`
+	Not                  *schema           `json:"not,omitempty"`
+	Properties           *schemaMap        `json:"properties,omitempty"`
+	Required             []string          `json:"required,omitempty"`
+	AdditionalProperties *schema           `json:"additionalProperties,omitempty"`
+	Items                *schema           `json:"items,omitempty"`
+	AnyOf                []*schema         `json:"anyOf,omitempty"`
+	Examples             []json.RawMessage `json:"examples,omitempty"`
+}
+
+// schemaMap is a JSON object of schemas that keeps insertion order.
+type schemaMap struct {
+	keys []string
+	m    map[string]*schema
+}
+
+func (sm *schemaMap) set(key string, s *schema) {
+	if sm.m == nil {
+		sm.m = make(map[string]*schema)
+	}
+	if _, ok := sm.m[key]; !ok {
+		sm.keys = append(sm.keys, key)
+	}
+	sm.m[key] = s
+}
+
+func (sm *schemaMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range sm.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(sm.m[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// document is the generated schema file.
+type document struct {
+	Schema      string     `json:"$schema"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Ref         string     `json:"$ref"`
+	Defs        *schemaMap `json:"$defs"`
+}
+
+func marshalIndent(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func ref(name string) *schema {
+	return &schema{Ref: "#/$defs/" + name}
+}
+
+// ---------------------------------------------------------------------------
+// protocol.go parsing
+// ---------------------------------------------------------------------------
+
+// protocol holds the declarations of protocol.go.
+type protocol struct {
+	enums      map[string][]string // enum type -> values in declaration order
+	consts     map[string]string   // constant name -> value
+	structs    map[string]*ast.StructType
+	docs       map[string]string // type name -> doc comment
+	order      []string          // type names in declaration order
+	enumOfType map[string]string // constant name -> enum type
+}
+
+func parseProtocol(filename string) (*protocol, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	p := &protocol{
+		enums:      make(map[string][]string),
+		consts:     make(map[string]string),
+		structs:    make(map[string]*ast.StructType),
+		docs:       make(map[string]string),
+		enumOfType: make(map[string]string),
+	}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				name := s.Name.Name
+				if s.TypeParams != nil {
+					continue // Nullable
+				}
+				doc := gd.Doc
+				if s.Doc != nil {
+					doc = s.Doc
+				}
+				if doc != nil {
+					p.docs[name] = doc.Text()
+				}
+				switch t := s.Type.(type) {
+				case *ast.StructType:
+					p.structs[name] = t
+					p.order = append(p.order, name)
+				case *ast.Ident:
+					if t.Name == "string" {
+						p.enums[name] = nil
+						p.order = append(p.order, name)
+					}
+				}
+			case *ast.ValueSpec:
+				if gd.Tok != token.CONST || s.Type == nil {
+					continue
+				}
+				typ, ok := s.Type.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				for i, n := range s.Names {
+					lit, ok := s.Values[i].(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						continue
+					}
+					v, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						return nil, fmt.Errorf("constant %s: %w", n.Name, err)
+					}
+					p.enums[typ.Name] = append(p.enums[typ.Name], v)
+					p.consts[n.Name] = v
+					p.enumOfType[n.Name] = typ.Name
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// document builds the schema document.
+func (p *protocol) document() (*document, error) {
+	defs := &schemaMap{}
+	variantOf := make(map[string]variant)
+	for _, u := range unions {
+		s := &schema{Title: u.name, Description: u.description}
+		for _, v := range u.variants {
+			st, ok := p.structs[v.typeName]
+			if !ok {
+				return nil, fmt.Errorf("union %s: unknown type %s", u.name, v.typeName)
+			}
+			if base := embeddedBase(st); base != u.base {
+				return nil, fmt.Errorf("union %s: type %s does not embed %s", u.name, v.typeName, u.base)
+			}
+			variantOf[v.typeName] = v
+			s.AnyOf = append(s.AnyOf, ref(v.typeName))
+		}
+		defs.set(u.name, s)
+	}
+
+	inlined := p.embeddedStructs()
+	for _, name := range p.order {
+		if strings.HasPrefix(name, "Unknown") || strings.HasSuffix(name, "Base") || inlined[name] {
+			continue
+		}
+		if values, ok := p.enums[name]; ok {
+			if len(values) == 0 {
+				continue
+			}
+			defs.set(name, &schema{Title: name, Type: "string", Enum: values})
+			continue
+		}
+		st := p.structs[name]
+		if base := embeddedBase(st); base != "" {
+			if _, ok := variantOf[name]; !ok {
+				return nil, fmt.Errorf("type %s embeds %s but is not a variant of a union", name, base)
+			}
+		}
+		s, err := p.structSchema(name, st, variantOf[name])
+		if err != nil {
+			return nil, err
+		}
+		defs.set(name, s)
+	}
+
+	return &document{
+		Schema:      "https://json-schema.org/draft/2020-12/schema",
+		Title:       "Claude Code CLI stream-json protocol",
+		Description: "Generated from protocol.go by cmd/genschema. Do not edit.",
+		Ref:         "#/$defs/Message",
+		Defs:        defs,
+	}, nil
+}
+
+// embeddedStructs returns the structs embedded by other structs. Their
+// fields are inlined, so they get no definition of their own.
+func (p *protocol) embeddedStructs() map[string]bool {
+	embedded := make(map[string]bool)
+	for _, st := range p.structs {
+		for _, f := range st.Fields.List {
+			if id, ok := f.Type.(*ast.Ident); ok && len(f.Names) == 0 {
+				embedded[id.Name] = true
+			}
+		}
+	}
+	return embedded
+}
+
+// embeddedBase returns the name of the *Base struct embedded in st, if any.
+func embeddedBase(st *ast.StructType) string {
+	for _, f := range st.Fields.List {
+		if id, ok := f.Type.(*ast.Ident); ok && len(f.Names) == 0 && strings.HasSuffix(id.Name, "Base") {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+// structSchema returns the schema of the struct name. v holds the
+// discriminator constants if the struct is a union variant.
+func (p *protocol) structSchema(name string, st *ast.StructType, v variant) (*schema, error) {
+	desc, examples := splitDoc(p.docs[name])
+	s := &schema{
+		Title:       name,
+		Description: desc,
+		Type:        "object",
+		Properties:  &schemaMap{},
+		Examples:    examples,
+	}
+	if err := p.addFields(s, st, v); err != nil {
+		return nil, fmt.Errorf("type %s: %w", name, err)
+	}
+	for prop, val := range v.consts {
+		if _, ok := s.Properties.m[prop]; !ok {
+			return nil, fmt.Errorf("type %s: no property %s", name, prop)
+		}
+		s.Properties.m[prop].Const = val
+	}
+	if name == "ResultOtherMessage" {
+		// Any result subtype without a dedicated type.
+		var known []string
+		for _, u := range unions {
+			for _, o := range u.variants {
+				if o.typ == v.typ && o.subtype != "" {
+					known = append(known, p.consts[o.subtype])
+				}
+			}
+		}
+		s.Properties.set("subtype", &schema{Type: "string", Not: &schema{Enum: known}})
+		s.Required = append(s.Required, "subtype")
+	}
+	return s, nil
+}
+
+// addFields adds the JSON fields of st to s, inlining embedded structs.
+func (p *protocol) addFields(s *schema, st *ast.StructType, v variant) error {
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			id, ok := f.Type.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("unsupported embedded field %T", f.Type)
+			}
+			if !strings.HasSuffix(id.Name, "Base") {
+				// Shared fields such as ResultCommon are inlined as they are.
+				st, ok := p.structs[id.Name]
+				if !ok {
+					return fmt.Errorf("unknown embedded type %s", id.Name)
+				}
+				if err := p.addFields(s, st, variant{}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := p.addBaseFields(s, id.Name, v); err != nil {
+				return err
+			}
+			continue
+		}
+		jsonName, omitempty := jsonTag(f)
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Names[0].Name
+		}
+		fs, err := p.typeSchema(f.Type, omitempty)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Names[0].Name, err)
+		}
+		if f.Comment != nil {
+			fs.Description = strings.TrimSpace(f.Comment.Text())
+		}
+		s.Properties.set(jsonName, fs)
+		if !omitempty {
+			s.Required = append(s.Required, jsonName)
+		}
+	}
+	return nil
+}
+
+// addBaseFields adds the fields of the embedded struct base. The type and
+// subtype fields of a union variant become consts.
+func (p *protocol) addBaseFields(s *schema, base string, v variant) error {
+	st, ok := p.structs[base]
+	if !ok {
+		return fmt.Errorf("unknown embedded type %s", base)
+	}
+	for _, f := range st.Fields.List {
+		jsonName, _ := jsonTag(f)
+		var c string
+		switch jsonName {
+		case "-":
+			continue
+		case "type":
+			c = v.typ
+		case "subtype":
+			c = v.subtype
+		}
+		if c == "" {
+			if jsonName == "type" || v.typeName == "" {
+				fs, err := p.typeSchema(f.Type, false)
+				if err != nil {
+					return err
+				}
+				s.Properties.set(jsonName, fs)
+				s.Required = append(s.Required, jsonName)
+			}
+			continue // subtype of a message without one
+		}
+		val, ok := p.consts[c]
+		if !ok {
+			return fmt.Errorf("unknown constant %s", c)
+		}
+		if want := typeName(f.Type); p.enumOfType[c] != want {
+			return fmt.Errorf("constant %s is not a %s", c, want)
+		}
+		s.Properties.set(jsonName, &schema{Const: val})
+		s.Required = append(s.Required, jsonName)
+	}
+	return nil
+}
+
+// typeSchema returns the schema of a field type. Pointers are nullable
+// unless the field is omitted when empty.
+func (p *protocol) typeSchema(expr ast.Expr, omitempty bool) (*schema, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return &schema{Type: "string"}, nil
+		case "bool":
+			return &schema{Type: "boolean"}, nil
+		case "int", "int64":
+			return &schema{Type: "integer"}, nil
+		case "float64":
+			return &schema{Type: "number"}, nil
+		case "any":
+			return &schema{}, nil
+		}
+		if u, ok := interfaceUnions[t.Name]; ok {
+			return ref(u), nil
+		}
+		if _, ok := p.enums[t.Name]; ok {
+			return ref(t.Name), nil
+		}
+		if _, ok := p.structs[t.Name]; ok {
+			return ref(t.Name), nil
+		}
+		return nil, fmt.Errorf("unsupported type %s", t.Name)
+	case *ast.SelectorExpr:
+		if typeName(t) == "json.RawMessage" {
+			return &schema{}, nil
+		}
+	case *ast.StarExpr:
+		elem, err := p.typeSchema(t.X, false)
+		if err != nil || omitempty {
+			return elem, err
+		}
+		return &schema{AnyOf: []*schema{elem, {Type: "null"}}}, nil
+	case *ast.ArrayType:
+		elem, err := p.typeSchema(t.Elt, false)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: elem}, nil
+	case *ast.MapType:
+		elem, err := p.typeSchema(t.Value, false)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(elem, &schema{}) {
+			return &schema{Type: "object"}, nil
+		}
+		return &schema{Type: "object", AdditionalProperties: elem}, nil
+	case *ast.IndexExpr:
+		if id, ok := t.X.(*ast.Ident); ok && id.Name == "Nullable" {
+			elem, err := p.typeSchema(t.Index, false)
+			if err != nil {
+				return nil, err
+			}
+			if typ, ok := elem.Type.(string); ok && elem.Ref == "" && elem.Items == nil {
+				elem.Type = []string{typ, "null"}
+				return elem, nil
+			}
+			return &schema{AnyOf: []*schema{elem, {Type: "null"}}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", typeName(expr))
+}
+
+// typeName returns the source form of a simple type expression.
+func typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeName(t.X) + "." + t.Sel.Name
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// jsonTag returns the JSON name and omitempty option of a struct field.
+func jsonTag(f *ast.Field) (name string, omitempty bool) {
+	if f.Tag == nil {
+		return "", false
+	}
+	tag, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(reflect.StructTag(tag).Get("json"), ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty
+}
+
+// splitDoc splits a doc comment into its description and the ```json
+// examples it contains. "#" heading lines are dropped and the lines of each
+// paragraph are joined.
+func splitDoc(doc string) (desc string, examples []json.RawMessage) {
+	var paras []string
+	var para, example []string
+	inExample := false
+	flush := func() {
+		if len(para) > 0 {
+			paras = append(paras, strings.Join(para, " "))
+			para = nil
+		}
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "```json":
+			flush()
+			inExample = true
+		case inExample && trimmed == "```":
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, []byte(strings.Join(example, "\n"))); err == nil {
+				examples = append(examples, buf.Bytes())
+			}
+			example = nil
+			inExample = false
+		case inExample:
+			example = append(example, line)
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			flush()
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return strings.Join(paras, "\n\n"), examples
+}
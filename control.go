@@ -0,0 +1,205 @@
+package ccprotocol
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultControlTimeout is how long Controller.Request waits for the
+// matching control_response when Controller.Timeout is zero.
+const DefaultControlTimeout = 30 * time.Second
+
+// ErrControllerClosed is returned by Controller.Request once the Controller
+// has been closed.
+var ErrControllerClosed = errors.New("control: controller closed")
+
+// ControlError is returned by Controller.Request when the CLI answers with a
+// control_response of subtype "error".
+type ControlError struct {
+	RequestID string
+	Subtype   ControlSubtype // Subtype of the failed request
+	Message   string         // Error message from the response
+}
+
+func (e *ControlError) Error() string {
+	return fmt.Sprintf("control request %s (%s): %s", e.Subtype, e.RequestID, e.Message)
+}
+
+// ControlHandler answers a control request sent by the CLI, such as
+// can_use_tool. The returned value becomes the payload of a "success"
+// control_response; a non-nil error is sent as an "error" control_response
+// instead. ctx is cancelled when the Controller is closed.
+type ControlHandler func(ctx context.Context, req IsControlRequest) (any, error)
+
+// Controller correlates control_request and control_response messages on a
+// CLI session. Request sends a control_request with a generated request ID
+// and waits for the matching response; handlers registered with Handle answer
+// requests sent by the CLI. Controller does not read from the CLI itself: the
+// caller feeds every decoded stdout message to Dispatch, so Request only
+// returns while another goroutine is reading.
+type Controller struct {
+	// Timeout bounds how long Request waits for a response
+	// (default DefaultControlTimeout).
+	Timeout time.Duration
+
+	send   func(context.Context, IsMessage) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	seq    atomic.Uint64
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	pending  map[string]chan ControlResponseBody
+	handlers map[ControlSubtype]ControlHandler
+	closed   bool
+}
+
+// NewController returns a Controller that writes control messages with send,
+// typically (*client.Client).Send. send must be safe for concurrent use.
+func NewController(send func(context.Context, IsMessage) error) *Controller {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Controller{
+		send:     send,
+		ctx:      ctx,
+		cancel:   cancel,
+		pending:  map[string]chan ControlResponseBody{},
+		handlers: map[ControlSubtype]ControlHandler{},
+	}
+}
+
+// Handle registers h for control requests of the given subtype sent by the
+// CLI. A nil h removes the handler.
+func (c *Controller) Handle(subtype ControlSubtype, h ControlHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h == nil {
+		delete(c.handlers, subtype)
+		return
+	}
+	c.handlers[subtype] = h
+}
+
+// Request sends req as a control_request and waits for the matching
+// control_response. It returns the response payload converted with
+// DecodeControlResponse, a *ControlError if the CLI reports an error, or a
+// context error if ctx ends or Timeout elapses first.
+func (c *Controller) Request(ctx context.Context, req IsControlRequest) (any, error) {
+	subtype := req.controlSubtype()
+	id := c.newRequestID()
+	ch := make(chan ControlResponseBody, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrControllerClosed
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultControlTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := c.send(ctx, &ControlRequestMessage{
+		MessageBase: MessageBase{Type: TypeControlRequest},
+		RequestID:   id,
+		Request:     req,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("send %s control request: %w", subtype, err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Subtype == "error" {
+			return nil, &ControlError{RequestID: id, Subtype: subtype, Message: resp.Error}
+		}
+		return DecodeControlResponse(subtype, resp.Response)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("await %s control response (%s): %w", subtype, id, ctx.Err())
+	case <-c.ctx.Done():
+		return nil, ErrControllerClosed
+	}
+}
+
+// Dispatch routes a message read from the CLI. A control_response answering
+// a pending Request is delivered to it, and a control_request with a
+// registered handler is answered in a new goroutine. Dispatch reports whether
+// it consumed msg; all other messages are left to the caller.
+func (c *Controller) Dispatch(msg IsMessage) bool {
+	switch m := msg.(type) {
+	case *ControlResponseMessage:
+		c.mu.Lock()
+		ch, ok := c.pending[m.Response.RequestID]
+		delete(c.pending, m.Response.RequestID)
+		c.mu.Unlock()
+		if ok {
+			ch <- m.Response
+		}
+		return ok
+	case *ControlRequestMessage:
+		if m.Request == nil {
+			return false
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		h := c.handlers[m.Request.controlSubtype()]
+		if h == nil || c.closed {
+			return false
+		}
+		c.wg.Add(1)
+		go c.serve(m, h)
+		return true
+	}
+	return false
+}
+
+// serve runs h for m and sends its result as a control_response.
+func (c *Controller) serve(m *ControlRequestMessage, h ControlHandler) {
+	defer c.wg.Done()
+	body := ControlResponseBody{Subtype: "success", RequestID: m.RequestID}
+	resp, err := h(c.ctx, m.Request)
+	if err != nil {
+		body.Subtype = "error"
+		body.Error = err.Error()
+	} else {
+		body.Response = resp
+	}
+	// A send error means stdin is gone, which ends the session anyway.
+	_ = c.send(c.ctx, &ControlResponseMessage{
+		MessageBase: MessageBase{Type: TypeControlResponse},
+		Response:    body,
+	})
+}
+
+// Close fails pending and future Requests with ErrControllerClosed, cancels
+// the context passed to handlers and waits for running handlers to return.
+func (c *Controller) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.cancel()
+	c.wg.Wait()
+}
+
+// newRequestID returns a request ID unique within the Controller, in the
+// "req_<n>_<random>" form used by the SDKs.
+func (c *Controller) newRequestID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("req_%d_%s", c.seq.Add(1), hex.EncodeToString(b[:]))
+}
@@ -0,0 +1,169 @@
+package ccprotocol_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// fakeTransport records the messages a Controller sends.
+type fakeTransport struct {
+	sent chan IsMessage
+}
+
+func newController(t *testing.T) (*Controller, *fakeTransport) {
+	t.Helper()
+	tr := &fakeTransport{sent: make(chan IsMessage, 16)}
+	c := NewController(func(_ context.Context, m IsMessage) error {
+		tr.sent <- m
+		return nil
+	})
+	t.Cleanup(c.Close)
+	return c, tr
+}
+
+func (tr *fakeTransport) next(t *testing.T) IsMessage {
+	t.Helper()
+	select {
+	case m := <-tr.sent:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message sent")
+		return nil
+	}
+}
+
+func TestController_Request(t *testing.T) {
+	c, tr := newController(t)
+
+	type result struct {
+		v   any
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := c.Request(context.Background(), SetPermissionModeRequest{
+			ControlRequestBase: ControlRequestBase{Subtype: ControlSetPermissionMode},
+			Mode:               PermissionPlan,
+		})
+		done <- result{v, err}
+	}()
+
+	req := tr.next(t).(*ControlRequestMessage)
+	if req.RequestID == "" {
+		t.Fatal("RequestID is empty")
+	}
+	// A response to another request is left to the caller.
+	other := &ControlResponseMessage{Response: ControlResponseBody{Subtype: "success", RequestID: "other"}}
+	if c.Dispatch(other) {
+		t.Error("Dispatch consumed a response to an unknown request")
+	}
+	resp := &ControlResponseMessage{Response: ControlResponseBody{Subtype: "success", RequestID: req.RequestID, Response: map[string]any{"mode": "plan"}}}
+	if !c.Dispatch(resp) {
+		t.Error("Dispatch did not consume the matching response")
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if want := (&SetPermissionModeResponse{Mode: PermissionPlan}); !reflect.DeepEqual(r.v, want) {
+		t.Errorf("Request() = %#v, want %#v", r.v, want)
+	}
+}
+
+func TestController_RequestError(t *testing.T) {
+	c, tr := newController(t)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Request(context.Background(), SetModelRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlSetModel}, Model: "nope"})
+		errc <- err
+	}()
+	req := tr.next(t).(*ControlRequestMessage)
+	c.Dispatch(&ControlResponseMessage{Response: ControlResponseBody{Subtype: "error", RequestID: req.RequestID, Error: "unknown model"}})
+
+	var ctrlErr *ControlError
+	if err := <-errc; !errors.As(err, &ctrlErr) {
+		t.Fatalf("Request() error = %v, want *ControlError", err)
+	}
+	if ctrlErr.Subtype != ControlSetModel || ctrlErr.Message != "unknown model" || ctrlErr.RequestID != req.RequestID {
+		t.Errorf("ControlError = %+v", ctrlErr)
+	}
+}
+
+func TestController_RequestTimeout(t *testing.T) {
+	c, _ := newController(t)
+	c.Timeout = 20 * time.Millisecond
+
+	_, err := c.Request(context.Background(), InterruptRequest{ControlRequestBase{Subtype: ControlInterrupt}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestController_Close(t *testing.T) {
+	c, tr := newController(t)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Request(context.Background(), InterruptRequest{ControlRequestBase{Subtype: ControlInterrupt}})
+		errc <- err
+	}()
+	tr.next(t)
+	c.Close()
+	if err := <-errc; !errors.Is(err, ErrControllerClosed) {
+		t.Errorf("Request() error = %v, want ErrControllerClosed", err)
+	}
+	if _, err := c.Request(context.Background(), InterruptRequest{ControlRequestBase{Subtype: ControlInterrupt}}); !errors.Is(err, ErrControllerClosed) {
+		t.Errorf("Request() after Close error = %v, want ErrControllerClosed", err)
+	}
+}
+
+func TestController_Handle(t *testing.T) {
+	c, tr := newController(t)
+
+	// The handler blocks until both requests are in flight, so it only
+	// completes if requests are handled concurrently.
+	var started sync.WaitGroup
+	started.Add(2)
+	c.Handle(ControlCanUseTool, func(_ context.Context, req IsControlRequest) (any, error) {
+		started.Done()
+		started.Wait()
+		r := req.(CanUseToolRequest)
+		if r.ToolName == "Bash" {
+			return nil, errors.New("not allowed")
+		}
+		return PermissionPayload{Behavior: "allow", UpdatedInput: r.Input}, nil
+	})
+
+	for _, m := range []*ControlRequestMessage{
+		{MessageBase: MessageBase{Type: TypeControlRequest}, RequestID: "r1", Request: CanUseToolRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool}, ToolName: "Read", Input: map[string]any{}}},
+		{MessageBase: MessageBase{Type: TypeControlRequest}, RequestID: "r2", Request: CanUseToolRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool}, ToolName: "Bash", Input: map[string]any{}}},
+	} {
+		if !c.Dispatch(m) {
+			t.Fatalf("Dispatch did not consume request %s", m.RequestID)
+		}
+	}
+	// Requests without a handler are left to the caller.
+	if c.Dispatch(&ControlRequestMessage{RequestID: "r3", Request: HookCallbackRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlHookCallback}}}) {
+		t.Error("Dispatch consumed a request without a handler")
+	}
+
+	got := map[string]ControlResponseBody{}
+	for i := 0; i < 2; i++ {
+		m := tr.next(t).(*ControlResponseMessage)
+		got[m.Response.RequestID] = m.Response
+	}
+	if r := got["r1"]; r.Subtype != "success" || r.Response.(PermissionPayload).Behavior != "allow" {
+		t.Errorf("response r1 = %+v", r)
+	}
+	if r := got["r2"]; r.Subtype != "error" || r.Error != "not allowed" {
+		t.Errorf("response r2 = %+v", r)
+	}
+}
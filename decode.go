@@ -3,11 +3,52 @@ package ccprotocol
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 )
 
+// DecodeOption configures DecodeMessage and DecodeContentBlock.
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	lenient bool
+}
+
+// Lenient makes the decoder return UnknownMessage and UnknownBlock for
+// unrecognized types and subtypes instead of failing. Without it decoding
+// is strict and such input is an error.
+func Lenient() DecodeOption {
+	return func(o *decodeOptions) { o.lenient = true }
+}
+
+func newDecodeOptions(opts []DecodeOption) decodeOptions {
+	var o decodeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// options turns o back into options for the nested decoders.
+func (o decodeOptions) options() []DecodeOption {
+	if o.lenient {
+		return []DecodeOption{Lenient()}
+	}
+	return nil
+}
+
+// unknownMessage returns an UnknownMessage for data in lenient mode, or err otherwise.
+func (o decodeOptions) unknownMessage(data []byte, base MessageBase, err error) (IsMessage, error) {
+	if !o.lenient {
+		return nil, err
+	}
+	return &UnknownMessage{MessageBase: base, Raw: append(json.RawMessage(nil), data...)}, nil
+}
+
 // DecodeMessage decodes JSON into the correct concrete message type based on
 // the "type" and "subtype" fields. It returns a pointer to the concrete struct.
-func DecodeMessage(data []byte) (IsMessage, error) {
+func DecodeMessage(data []byte, opts ...DecodeOption) (IsMessage, error) {
+	o := newDecodeOptions(opts)
+
 	var base MessageBase
 	if err := json.Unmarshal(data, &base); err != nil {
 		return nil, fmt.Errorf("decode message base: %w", err)
@@ -15,17 +56,24 @@ func DecodeMessage(data []byte) (IsMessage, error) {
 
 	switch base.Type {
 	case TypeSystem:
-		return decodeSystemMessage(data, base.Subtype)
+		return decodeSystemMessage(data, base, o)
 	case TypeAssistant:
 		var m AssistantMessage
 		if err := json.Unmarshal(data, &m); err != nil {
 			return nil, fmt.Errorf("decode assistant message: %w", err)
 		}
+		if !o.lenient {
+			for i, c := range m.Message.Content {
+				if u, ok := c.(UnknownBlock); ok {
+					return nil, fmt.Errorf("decode assistant message: content[%d]: unknown content block type: %q", i, u.Type)
+				}
+			}
+		}
 		return &m, nil
 	case TypeUser:
-		return decodeUserMessage(data)
+		return decodeUserMessage(data, base, o)
 	case TypeResult:
-		return decodeResultMessage(data, base.Subtype)
+		return decodeResultMessage(data, base, o)
 	case TypeStreamEvent:
 		var m StreamEventMessage
 		if err := json.Unmarshal(data, &m); err != nil {
@@ -37,6 +85,9 @@ func DecodeMessage(data []byte) (IsMessage, error) {
 		if err := json.Unmarshal(data, &m); err != nil {
 			return nil, fmt.Errorf("decode control_request message: %w", err)
 		}
+		if u, ok := m.Request.(UnknownControlRequest); ok && !o.lenient {
+			return nil, fmt.Errorf("decode control_request message: unknown control request subtype: %q", u.Subtype)
+		}
 		return &m, nil
 	case TypeControlResponse:
 		var m ControlResponseMessage
@@ -45,13 +96,13 @@ func DecodeMessage(data []byte) (IsMessage, error) {
 		}
 		return &m, nil
 	default:
-		return nil, fmt.Errorf("unknown message type: %q", base.Type)
+		return o.unknownMessage(data, base, fmt.Errorf("unknown message type: %q", base.Type))
 	}
 }
 
 // decodeSystemMessage dispatches on the subtype for system messages.
-func decodeSystemMessage(data []byte, subtype MessageSubtype) (IsMessage, error) {
-	switch subtype {
+func decodeSystemMessage(data []byte, base MessageBase, o decodeOptions) (IsMessage, error) {
+	switch base.Subtype {
 	case SubtypeInit:
 		var m SystemInitMessage
 		if err := json.Unmarshal(data, &m); err != nil {
@@ -65,13 +116,16 @@ func decodeSystemMessage(data []byte, subtype MessageSubtype) (IsMessage, error)
 		}
 		return &m, nil
 	default:
-		return nil, fmt.Errorf("unknown system subtype: %q", subtype)
+		return o.unknownMessage(data, base, fmt.Errorf("unknown system subtype: %q", base.Subtype))
 	}
 }
 
 // decodeUserMessage distinguishes between UserReplayMessage, UserToolResultMessage,
-// and UserTextMessage by inspecting the raw JSON fields.
-func decodeUserMessage(data []byte) (IsMessage, error) {
+// UserContentMessage and UserTextMessage by inspecting the raw JSON fields. A
+// content array made of tool_result blocks is a UserToolResultMessage; any
+// other content array is a UserContentMessage, whose unknown blocks are
+// rejected unless Lenient is given.
+func decodeUserMessage(data []byte, base MessageBase, o decodeOptions) (IsMessage, error) {
 	// Peek at discriminating fields without fully unmarshaling.
 	var peek struct {
 		IsReplay bool            `json:"isReplay"`
@@ -98,6 +152,24 @@ func decodeUserMessage(data []byte) (IsMessage, error) {
 	}
 
 	if len(msgPeek.Content) > 0 && msgPeek.Content[0] == '[' {
+		var blocks []json.RawMessage
+		if err := json.Unmarshal(msgPeek.Content, &blocks); err != nil {
+			return nil, fmt.Errorf("decode user tool_result message: %w", err)
+		}
+		if !allToolResults(blocks) {
+			var m UserContentMessage
+			if err := json.Unmarshal(data, &m); err != nil {
+				return nil, fmt.Errorf("decode user content message: %w", err)
+			}
+			if !o.lenient {
+				for i, c := range m.Message.Content {
+					if u, ok := c.(UnknownBlock); ok {
+						return nil, fmt.Errorf("decode user content message: content[%d]: unknown content block type: %q", i, u.Type)
+					}
+				}
+			}
+			return &m, nil
+		}
 		var m UserToolResultMessage
 		if err := json.Unmarshal(data, &m); err != nil {
 			return nil, fmt.Errorf("decode user tool_result message: %w", err)
@@ -112,9 +184,23 @@ func decodeUserMessage(data []byte) (IsMessage, error) {
 	return &m, nil
 }
 
-// decodeResultMessage dispatches on the subtype for result messages.
-func decodeResultMessage(data []byte, subtype MessageSubtype) (IsMessage, error) {
-	switch subtype {
+// allToolResults reports whether every block is a tool_result block. An empty
+// array counts as tool results.
+func allToolResults(blocks []json.RawMessage) bool {
+	for _, c := range blocks {
+		var b ContentBlockBase
+		if err := json.Unmarshal(c, &b); err != nil || b.Type != BlockToolResult {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeResultMessage dispatches on the subtype for result messages. A
+// subtype without a dedicated type that does not fit ResultOtherMessage is
+// rejected unless Lenient is given.
+func decodeResultMessage(data []byte, base MessageBase, o decodeOptions) (IsMessage, error) {
+	switch base.Subtype {
 	case SubtypeSuccess:
 		var m ResultSuccessMessage
 		if err := json.Unmarshal(data, &m); err != nil {
@@ -134,13 +220,19 @@ func decodeResultMessage(data []byte, subtype MessageSubtype) (IsMessage, error)
 		}
 		return &m, nil
 	default:
-		return nil, fmt.Errorf("unknown result subtype: %q", subtype)
+		var m ResultOtherMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			return o.unknownMessage(data, base, fmt.Errorf("decode result/%s message: %w", base.Subtype, err))
+		}
+		return &m, nil
 	}
 }
 
 // DecodeContentBlock decodes JSON into the correct content block type based on
 // the "type" field. It returns a value (not a pointer).
-func DecodeContentBlock(data []byte) (IsContentBlock, error) {
+func DecodeContentBlock(data []byte, opts ...DecodeOption) (IsContentBlock, error) {
+	o := newDecodeOptions(opts)
+
 	var base ContentBlockBase
 	if err := json.Unmarshal(data, &base); err != nil {
 		return nil, fmt.Errorf("decode content block base: %w", err)
@@ -172,12 +264,192 @@ func DecodeContentBlock(data []byte) (IsContentBlock, error) {
 		}
 		return b, nil
 	default:
-		return nil, fmt.Errorf("unknown content block type: %q", base.Type)
+		if !o.lenient {
+			return nil, fmt.Errorf("unknown content block type: %q", base.Type)
+		}
+		return UnknownBlock{ContentBlockBase: base, Raw: append(json.RawMessage(nil), data...)}, nil
+	}
+}
+
+// DecodeControlRequest decodes JSON into the correct control request type
+// based on the "subtype" field. It returns a value (not a pointer).
+func DecodeControlRequest(data []byte, opts ...DecodeOption) (IsControlRequest, error) {
+	o := newDecodeOptions(opts)
+
+	var base ControlRequestBase
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("decode control request base: %w", err)
+	}
+
+	switch base.Subtype {
+	case ControlCanUseTool:
+		var r CanUseToolRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode can_use_tool request: %w", err)
+		}
+		return r, nil
+	case ControlSetPermissionMode:
+		var r SetPermissionModeRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode set_permission_mode request: %w", err)
+		}
+		return r, nil
+	case ControlSetModel:
+		var r SetModelRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode set_model request: %w", err)
+		}
+		return r, nil
+	case ControlInterrupt:
+		var r InterruptRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode interrupt request: %w", err)
+		}
+		return r, nil
+	case ControlSetMaxThinkingTokens:
+		var r SetMaxThinkingTokensRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode set_max_thinking_tokens request: %w", err)
+		}
+		return r, nil
+	case ControlInitialize:
+		var r InitializeRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode initialize request: %w", err)
+		}
+		return r, nil
+	case ControlHookCallback:
+		var r HookCallbackRequest
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("decode hook_callback request: %w", err)
+		}
+		return r, nil
+	default:
+		if !o.lenient {
+			return nil, fmt.Errorf("unknown control request subtype: %q", base.Subtype)
+		}
+		return UnknownControlRequest{ControlRequestBase: base, Raw: append(json.RawMessage(nil), data...)}, nil
+	}
+}
+
+// DecodeControlResponse converts the response payload of a successful
+// control_response into the typed response for the request subtype it
+// answers: *PermissionPayload for can_use_tool, *SetPermissionModeResponse
+// for set_permission_mode and *InitializeResponse for initialize. The
+// payload is typically the map produced by decoding a ControlResponseMessage.
+// Subtypes without a modeled response and nil payloads are returned unchanged.
+func DecodeControlResponse(subtype ControlSubtype, response any) (any, error) {
+	var v any
+	switch subtype {
+	case ControlCanUseTool:
+		v = &PermissionPayload{}
+	case ControlSetPermissionMode:
+		v = &SetPermissionModeResponse{}
+	case ControlInitialize:
+		v = &InitializeResponse{}
+	default:
+		return response, nil
+	}
+	if response == nil {
+		return nil, nil
+	}
+	if err := convert(response, v); err != nil {
+		return nil, fmt.Errorf("decode %s response: %w", subtype, err)
+	}
+	return v, nil
+}
+
+// DecodeResponse returns the typed response payload for a request of the
+// given subtype. See DecodeControlResponse.
+func (b ControlResponseBody) DecodeResponse(subtype ControlSubtype) (any, error) {
+	return DecodeControlResponse(subtype, b.Response)
+}
+
+// DecodeStreamEvent decodes JSON into the correct stream event type based on
+// the "type" field. It returns a value (not a pointer). Unrecognized event
+// types and content blocks are rejected unless Lenient is given.
+func DecodeStreamEvent(data []byte, opts ...DecodeOption) (IsStreamEvent, error) {
+	o := newDecodeOptions(opts)
+
+	var base StreamEventBase
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("decode stream event base: %w", err)
+	}
+
+	switch base.Type {
+	case EventMessageStart:
+		var e MessageStartEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode message_start event: %w", err)
+		}
+		return e, nil
+	case EventContentBlockStart:
+		var e ContentBlockStartEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode content_block_start event: %w", err)
+		}
+		if u, ok := e.ContentBlock.(UnknownBlock); ok && !o.lenient {
+			return nil, fmt.Errorf("decode content_block_start event: unknown content block type: %q", u.Type)
+		}
+		return e, nil
+	case EventContentBlockDelta:
+		var e ContentBlockDeltaEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode content_block_delta event: %w", err)
+		}
+		return e, nil
+	case EventContentBlockStop:
+		var e ContentBlockStopEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode content_block_stop event: %w", err)
+		}
+		return e, nil
+	case EventMessageDelta:
+		var e MessageDeltaEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode message_delta event: %w", err)
+		}
+		return e, nil
+	case EventMessageStop:
+		var e MessageStopEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decode message_stop event: %w", err)
+		}
+		return e, nil
+	default:
+		if !o.lenient {
+			return nil, fmt.Errorf("unknown stream event type: %q", base.Type)
+		}
+		return UnknownStreamEvent{StreamEventBase: base, Raw: append(json.RawMessage(nil), data...)}, nil
+	}
+}
+
+// DecodeEvent returns the typed event of the message. See DecodeStreamEvent.
+func (m StreamEventMessage) DecodeEvent(opts ...DecodeOption) (IsStreamEvent, error) {
+	data, err := json.Marshal(m.Event)
+	if err != nil {
+		return nil, fmt.Errorf("decode stream event: %w", err)
 	}
+	return DecodeStreamEvent(data, opts...)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for Nullable. A JSON null leaves
+// the value invalid; anything else is decoded into Value.
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*n = Nullable[T]{}
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.Value); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
 }
 
 // UnmarshalJSON implements json.Unmarshaler for AssistantBody, handling the
-// polymorphic Content field via DecodeContentBlock.
+// polymorphic Content field via DecodeContentBlock. Unrecognized blocks are
+// kept as UnknownBlock; DecodeMessage rejects them unless Lenient is given.
 func (b *AssistantBody) UnmarshalJSON(data []byte) error {
 	// Use an alias to avoid infinite recursion.
 	type Alias AssistantBody
@@ -190,9 +462,12 @@ func (b *AssistantBody) UnmarshalJSON(data []byte) error {
 	}
 
 	*b = AssistantBody(raw.Alias)
+	if err := decodeExtra(data, reflect.TypeOf(raw.Alias), &b.Extra); err != nil {
+		return fmt.Errorf("decode assistant body: %w", err)
+	}
 	b.Content = make([]IsContentBlock, len(raw.Content))
 	for i, c := range raw.Content {
-		block, err := DecodeContentBlock(c)
+		block, err := DecodeContentBlock(c, Lenient())
 		if err != nil {
 			return fmt.Errorf("decode assistant body content[%d]: %w", i, err)
 		}
@@ -200,3 +475,387 @@ func (b *AssistantBody) UnmarshalJSON(data []byte) error {
 	}
 	return nil
 }
+
+// UnmarshalJSON implements json.Unmarshaler for UserContentBody, handling the
+// polymorphic Content field via DecodeContentBlock. Unrecognized blocks are
+// kept as UnknownBlock; DecodeMessage rejects them unless Lenient is given.
+func (b *UserContentBody) UnmarshalJSON(data []byte) error {
+	type Alias UserContentBody
+	var raw struct {
+		Alias
+		Content []json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("decode user content body: %w", err)
+	}
+
+	*b = UserContentBody(raw.Alias)
+	if err := decodeExtra(data, reflect.TypeOf(raw.Alias), &b.Extra); err != nil {
+		return fmt.Errorf("decode user content body: %w", err)
+	}
+	b.Content = make([]IsContentBlock, len(raw.Content))
+	for i, c := range raw.Content {
+		block, err := DecodeContentBlock(c, Lenient())
+		if err != nil {
+			return fmt.Errorf("decode user content body content[%d]: %w", i, err)
+		}
+		b.Content[i] = block
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ControlRequestMessage, handling
+// the polymorphic Request field via DecodeControlRequest. Unrecognized
+// subtypes are kept as UnknownControlRequest; DecodeMessage rejects them
+// unless Lenient is given.
+func (m *ControlRequestMessage) UnmarshalJSON(data []byte) error {
+	type Alias ControlRequestMessage
+	var raw struct {
+		Alias
+		Request json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("decode control_request message: %w", err)
+	}
+
+	*m = ControlRequestMessage(raw.Alias)
+	if err := decodeExtra(data, reflect.TypeOf(raw.Alias), &m.Extra); err != nil {
+		return fmt.Errorf("decode control_request message: %w", err)
+	}
+	if len(raw.Request) == 0 || string(raw.Request) == "null" {
+		return nil
+	}
+	req, err := DecodeControlRequest(raw.Request, Lenient())
+	if err != nil {
+		return fmt.Errorf("decode control_request message request: %w", err)
+	}
+	m.Request = req
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ContentBlockStartEvent,
+// handling the polymorphic ContentBlock field via DecodeContentBlock.
+// Unrecognized blocks are kept as UnknownBlock; DecodeStreamEvent rejects
+// them unless Lenient is given.
+func (e *ContentBlockStartEvent) UnmarshalJSON(data []byte) error {
+	type Alias ContentBlockStartEvent
+	var raw struct {
+		Alias
+		ContentBlock json.RawMessage `json:"content_block"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("decode content_block_start event: %w", err)
+	}
+
+	*e = ContentBlockStartEvent(raw.Alias)
+	if err := decodeExtra(data, reflect.TypeOf(raw.Alias), &e.Extra); err != nil {
+		return fmt.Errorf("decode content_block_start event: %w", err)
+	}
+	if len(raw.ContentBlock) == 0 || string(raw.ContentBlock) == "null" {
+		return nil
+	}
+	block, err := DecodeContentBlock(raw.ContentBlock, Lenient())
+	if err != nil {
+		return fmt.Errorf("decode content_block_start event content_block: %w", err)
+	}
+	e.ContentBlock = block
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Unmodeled fields
+// ---------------------------------------------------------------------------
+
+// UnmarshalJSON implements json.Unmarshaler for SystemInitMessage, keeping unmodeled fields in Extra.
+func (m *SystemInitMessage) UnmarshalJSON(data []byte) error {
+	type Alias SystemInitMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for SystemStatusMessage, keeping unmodeled fields in Extra.
+func (m *SystemStatusMessage) UnmarshalJSON(data []byte) error {
+	type Alias SystemStatusMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for AssistantMessage, keeping unmodeled fields in Extra.
+func (m *AssistantMessage) UnmarshalJSON(data []byte) error {
+	type Alias AssistantMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for UserTextMessage, keeping unmodeled fields in Extra.
+func (m *UserTextMessage) UnmarshalJSON(data []byte) error {
+	type Alias UserTextMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for UserToolResultMessage, keeping unmodeled fields in Extra.
+func (m *UserToolResultMessage) UnmarshalJSON(data []byte) error {
+	type Alias UserToolResultMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for UserContentMessage, keeping unmodeled fields in Extra.
+func (m *UserContentMessage) UnmarshalJSON(data []byte) error {
+	type Alias UserContentMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ResultSuccessMessage, keeping unmodeled fields in Extra.
+func (m *ResultSuccessMessage) UnmarshalJSON(data []byte) error {
+	type Alias ResultSuccessMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ResultOtherMessage, keeping unmodeled fields in Extra.
+func (m *ResultOtherMessage) UnmarshalJSON(data []byte) error {
+	type Alias ResultOtherMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ResultErrorMessage, keeping unmodeled fields in Extra.
+func (m *ResultErrorMessage) UnmarshalJSON(data []byte) error {
+	type Alias ResultErrorMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ResultMaxTurnsMessage, keeping unmodeled fields in Extra.
+func (m *ResultMaxTurnsMessage) UnmarshalJSON(data []byte) error {
+	type Alias ResultMaxTurnsMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for StreamEventMessage, keeping unmodeled fields in Extra.
+func (m *StreamEventMessage) UnmarshalJSON(data []byte) error {
+	type Alias StreamEventMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for UserReplayMessage, keeping unmodeled fields in Extra.
+func (m *UserReplayMessage) UnmarshalJSON(data []byte) error {
+	type Alias UserReplayMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ControlResponseMessage, keeping unmodeled fields in Extra.
+func (m *ControlResponseMessage) UnmarshalJSON(data []byte) error {
+	type Alias ControlResponseMessage
+	return unmarshalExtra(data, (*Alias)(m), &m.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for TextBlock, keeping unmodeled fields in Extra.
+func (b *TextBlock) UnmarshalJSON(data []byte) error {
+	type Alias TextBlock
+	return unmarshalExtra(data, (*Alias)(b), &b.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ToolUseBlock, keeping unmodeled fields in Extra.
+func (b *ToolUseBlock) UnmarshalJSON(data []byte) error {
+	type Alias ToolUseBlock
+	return unmarshalExtra(data, (*Alias)(b), &b.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ThinkingBlock, keeping unmodeled fields in Extra.
+func (b *ThinkingBlock) UnmarshalJSON(data []byte) error {
+	type Alias ThinkingBlock
+	return unmarshalExtra(data, (*Alias)(b), &b.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ToolResultBlock, keeping unmodeled fields in Extra.
+func (b *ToolResultBlock) UnmarshalJSON(data []byte) error {
+	type Alias ToolResultBlock
+	return unmarshalExtra(data, (*Alias)(b), &b.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for UserTextBody, keeping unmodeled fields in Extra.
+func (v *UserTextBody) UnmarshalJSON(data []byte) error {
+	type Alias UserTextBody
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for UserToolResultBody, keeping unmodeled fields in Extra.
+func (v *UserToolResultBody) UnmarshalJSON(data []byte) error {
+	type Alias UserToolResultBody
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for PermissionDenial, keeping unmodeled fields in Extra.
+func (v *PermissionDenial) UnmarshalJSON(data []byte) error {
+	type Alias PermissionDenial
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for CanUseToolRequest, keeping unmodeled fields in Extra.
+func (r *CanUseToolRequest) UnmarshalJSON(data []byte) error {
+	type Alias CanUseToolRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for SetPermissionModeRequest, keeping unmodeled fields in Extra.
+func (r *SetPermissionModeRequest) UnmarshalJSON(data []byte) error {
+	type Alias SetPermissionModeRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for SetModelRequest, keeping unmodeled fields in Extra.
+func (r *SetModelRequest) UnmarshalJSON(data []byte) error {
+	type Alias SetModelRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for InterruptRequest, keeping unmodeled fields in Extra.
+func (r *InterruptRequest) UnmarshalJSON(data []byte) error {
+	type Alias InterruptRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for SetMaxThinkingTokensRequest, keeping unmodeled fields in Extra.
+func (r *SetMaxThinkingTokensRequest) UnmarshalJSON(data []byte) error {
+	type Alias SetMaxThinkingTokensRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for InitializeRequest, keeping unmodeled fields in Extra.
+func (r *InitializeRequest) UnmarshalJSON(data []byte) error {
+	type Alias InitializeRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for HookCallbackRequest, keeping unmodeled fields in Extra.
+func (r *HookCallbackRequest) UnmarshalJSON(data []byte) error {
+	type Alias HookCallbackRequest
+	return unmarshalExtra(data, (*Alias)(r), &r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for HookMatcher, keeping unmodeled fields in Extra.
+func (v *HookMatcher) UnmarshalJSON(data []byte) error {
+	type Alias HookMatcher
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for AgentDefinition, keeping unmodeled fields in Extra.
+func (v *AgentDefinition) UnmarshalJSON(data []byte) error {
+	type Alias AgentDefinition
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for PermissionUpdate, keeping unmodeled fields in Extra.
+func (v *PermissionUpdate) UnmarshalJSON(data []byte) error {
+	type Alias PermissionUpdate
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for PermissionRuleValue, keeping unmodeled fields in Extra.
+func (v *PermissionRuleValue) UnmarshalJSON(data []byte) error {
+	type Alias PermissionRuleValue
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for SetPermissionModeResponse, keeping unmodeled fields in Extra.
+func (v *SetPermissionModeResponse) UnmarshalJSON(data []byte) error {
+	type Alias SetPermissionModeResponse
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for InitializeResponse, keeping unmodeled fields in Extra.
+func (v *InitializeResponse) UnmarshalJSON(data []byte) error {
+	type Alias InitializeResponse
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for SlashCommand, keeping unmodeled fields in Extra.
+func (v *SlashCommand) UnmarshalJSON(data []byte) error {
+	type Alias SlashCommand
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for AgentInfo, keeping unmodeled fields in Extra.
+func (v *AgentInfo) UnmarshalJSON(data []byte) error {
+	type Alias AgentInfo
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ModelInfo, keeping unmodeled fields in Extra.
+func (v *ModelInfo) UnmarshalJSON(data []byte) error {
+	type Alias ModelInfo
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for AccountInfo, keeping unmodeled fields in Extra.
+func (v *AccountInfo) UnmarshalJSON(data []byte) error {
+	type Alias AccountInfo
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ControlResponseBody, keeping unmodeled fields in Extra.
+func (v *ControlResponseBody) UnmarshalJSON(data []byte) error {
+	type Alias ControlResponseBody
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for Usage, keeping unmodeled fields in Extra.
+func (v *Usage) UnmarshalJSON(data []byte) error {
+	type Alias Usage
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ServerToolUse, keeping unmodeled fields in Extra.
+func (v *ServerToolUse) UnmarshalJSON(data []byte) error {
+	type Alias ServerToolUse
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for CacheCreation, keeping unmodeled fields in Extra.
+func (v *CacheCreation) UnmarshalJSON(data []byte) error {
+	type Alias CacheCreation
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ModelUsage, keeping unmodeled fields in Extra.
+func (v *ModelUsage) UnmarshalJSON(data []byte) error {
+	type Alias ModelUsage
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for MessageStartEvent, keeping unmodeled fields in Extra.
+func (e *MessageStartEvent) UnmarshalJSON(data []byte) error {
+	type Alias MessageStartEvent
+	return unmarshalExtra(data, (*Alias)(e), &e.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ContentBlockDeltaEvent, keeping unmodeled fields in Extra.
+func (e *ContentBlockDeltaEvent) UnmarshalJSON(data []byte) error {
+	type Alias ContentBlockDeltaEvent
+	return unmarshalExtra(data, (*Alias)(e), &e.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ContentBlockStopEvent, keeping unmodeled fields in Extra.
+func (e *ContentBlockStopEvent) UnmarshalJSON(data []byte) error {
+	type Alias ContentBlockStopEvent
+	return unmarshalExtra(data, (*Alias)(e), &e.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for MessageDeltaEvent, keeping unmodeled fields in Extra.
+func (e *MessageDeltaEvent) UnmarshalJSON(data []byte) error {
+	type Alias MessageDeltaEvent
+	return unmarshalExtra(data, (*Alias)(e), &e.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for MessageStopEvent, keeping unmodeled fields in Extra.
+func (e *MessageStopEvent) UnmarshalJSON(data []byte) error {
+	type Alias MessageStopEvent
+	return unmarshalExtra(data, (*Alias)(e), &e.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for ContentDelta, keeping unmodeled fields in Extra.
+func (v *ContentDelta) UnmarshalJSON(data []byte) error {
+	type Alias ContentDelta
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for MessageDelta, keeping unmodeled fields in Extra.
+func (v *MessageDelta) UnmarshalJSON(data []byte) error {
+	type Alias MessageDelta
+	return unmarshalExtra(data, (*Alias)(v), &v.Extra)
+}
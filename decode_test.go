@@ -1,6 +1,8 @@
 package ccprotocol_test
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 
 	. "github.com/hrntknr/claudecodeprotocol"
@@ -298,11 +300,100 @@ func TestDecodeMessage_ControlRequest(t *testing.T) {
 	if m.RequestID != "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee" {
 		t.Errorf("RequestID = %q, want %q", m.RequestID, "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee")
 	}
-	if m.Request.Subtype != ControlSetPermissionMode {
-		t.Errorf("Request.Subtype = %q, want %q", m.Request.Subtype, ControlSetPermissionMode)
+	req, ok := m.Request.(SetPermissionModeRequest)
+	if !ok {
+		t.Fatalf("Request type = %T, want SetPermissionModeRequest", m.Request)
+	}
+	if req.Subtype != ControlSetPermissionMode {
+		t.Errorf("Request.Subtype = %q, want %q", req.Subtype, ControlSetPermissionMode)
+	}
+	if req.Mode != PermissionPlan {
+		t.Errorf("Request.Mode = %q, want %q", req.Mode, PermissionPlan)
 	}
-	if m.Request.Mode != "plan" {
-		t.Errorf("Request.Mode = %q, want %q", m.Request.Mode, "plan")
+}
+
+func TestDecodeMessage_ControlRequestSubtypes(t *testing.T) {
+	tests := []struct {
+		data string
+		want IsControlRequest
+	}{
+		{`{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"ls"},"tool_use_id":"toolu_001"}`,
+			CanUseToolRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool}, ToolName: "Bash", Input: map[string]any{"command": "ls"}, ToolUseID: "toolu_001"}},
+		{`{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"rm -f /tmp/x"},"tool_use_id":"toolu_001","permission_suggestions":[{"type":"addRules","rules":[{"toolName":"Bash","ruleContent":"rm -f /tmp/x"}],"behavior":"allow","destination":"localSettings"},{"type":"addDirectories","directories":["/tmp"],"destination":"session"},{"type":"setMode","mode":"acceptEdits","destination":"session"}],"blocked_path":"/tmp/x"}`,
+			CanUseToolRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+				ToolName:           "Bash",
+				Input:              map[string]any{"command": "rm -f /tmp/x"},
+				ToolUseID:          "toolu_001",
+				PermissionSuggestions: []PermissionUpdate{
+					{Type: PermissionUpdateAddRules, Rules: []PermissionRuleValue{{ToolName: "Bash", RuleContent: "rm -f /tmp/x"}}, Behavior: "allow", Destination: DestinationLocalSettings},
+					{Type: PermissionUpdateAddDirectories, Directories: []string{"/tmp"}, Destination: DestinationSession},
+					{Type: PermissionUpdateSetMode, Mode: PermissionAcceptEdits, Destination: DestinationSession},
+				},
+				BlockedPath: "/tmp/x",
+			}},
+		{`{"subtype":"set_permission_mode","mode":"acceptEdits"}`,
+			SetPermissionModeRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlSetPermissionMode}, Mode: PermissionAcceptEdits}},
+		{`{"subtype":"set_model","model":"sonnet"}`,
+			SetModelRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlSetModel}, Model: "sonnet"}},
+		{`{"subtype":"interrupt"}`,
+			InterruptRequest{ControlRequestBase{Subtype: ControlInterrupt}}},
+		{`{"subtype":"set_max_thinking_tokens","max_thinking_tokens":1024}`,
+			SetMaxThinkingTokensRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlSetMaxThinkingTokens}, MaxThinkingTokens: NewNullable(1024)}},
+		{`{"subtype":"set_max_thinking_tokens","max_thinking_tokens":null}`,
+			SetMaxThinkingTokensRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlSetMaxThinkingTokens}}},
+		{`{"subtype":"initialize","hooks":{"PreToolUse":[{"matcher":"Bash","hookCallbackIds":["hook_0"]}]},"agents":{"reviewer":{"description":"Reviews code","prompt":"Review the diff","tools":["Read"]}}}`,
+			InitializeRequest{
+				ControlRequestBase: ControlRequestBase{Subtype: ControlInitialize},
+				Hooks:              map[string][]HookMatcher{"PreToolUse": {{Matcher: "Bash", HookCallbackIDs: []string{"hook_0"}}}},
+				Agents:             map[string]AgentDefinition{"reviewer": {Description: "Reviews code", Prompt: "Review the diff", Tools: []string{"Read"}}},
+			}},
+		{`{"subtype":"hook_callback","callback_id":"hook_0","input":{"hook_event_name":"PreToolUse"},"tool_use_id":"toolu_001"}`,
+			HookCallbackRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlHookCallback}, CallbackID: "hook_0", Input: map[string]any{"hook_event_name": "PreToolUse"}, ToolUseID: "toolu_001"}},
+	}
+	for _, tt := range tests {
+		data := []byte(`{"type":"control_request","request_id":"req_1","request":` + tt.data + `}`)
+		msg, err := DecodeMessage(data)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.data, err)
+		}
+		got := msg.(*ControlRequestMessage).Request
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Request = %#v\n  want %#v", got, tt.want)
+		}
+		enc, err := EncodeMessage(msg)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if string(enc) != string(data) {
+			t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", enc, data)
+		}
+	}
+}
+
+func TestDecodeMessage_UnknownControlRequestSubtype(t *testing.T) {
+	data := []byte(`{"type":"control_request","request_id":"req_1","request":{"subtype":"mcp_message","server_name":"s"}}`)
+	if _, err := DecodeMessage(data); err == nil {
+		t.Fatal("expected error for unknown control request subtype, got nil")
+	}
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u, ok := msg.(*ControlRequestMessage).Request.(UnknownControlRequest)
+	if !ok {
+		t.Fatalf("Request type = %T, want UnknownControlRequest", msg.(*ControlRequestMessage).Request)
+	}
+	if u.Subtype != "mcp_message" {
+		t.Errorf("Subtype = %q, want %q", u.Subtype, "mcp_message")
+	}
+	enc, err := EncodeMessage(msg)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(enc) != string(data) {
+		t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", enc, data)
 	}
 }
 
@@ -333,6 +424,70 @@ func TestDecodeMessage_ControlResponse(t *testing.T) {
 	}
 }
 
+func TestDecodeControlResponse(t *testing.T) {
+	tests := []struct {
+		subtype  ControlSubtype
+		response any
+		want     any
+	}{
+		{ControlCanUseTool, map[string]any{"behavior": "allow", "updatedInput": map[string]any{"command": "ls"}},
+			&PermissionPayload{Behavior: "allow", UpdatedInput: map[string]any{"command": "ls"}}},
+		{ControlSetPermissionMode, map[string]any{"mode": "plan"}, &SetPermissionModeResponse{Mode: PermissionPlan}},
+		{ControlInitialize, map[string]any{
+			"commands":                []any{map[string]any{"name": "review", "description": "Review a pull request", "argumentHint": ""}},
+			"output_style":            "default",
+			"available_output_styles": []any{"default"},
+			"models":                  []any{map[string]any{"value": "sonnet", "displayName": "Sonnet", "description": "Everyday tasks"}},
+			"account":                 map[string]any{"tokenSource": "none", "apiKeySource": "ANTHROPIC_API_KEY"},
+			"pid":                     1234.0,
+		}, &InitializeResponse{
+			Commands:              []SlashCommand{{Name: "review", Description: "Review a pull request"}},
+			OutputStyle:           "default",
+			AvailableOutputStyles: []string{"default"},
+			Models:                []ModelInfo{{Value: "sonnet", DisplayName: "Sonnet", Description: "Everyday tasks"}},
+			Account:               &AccountInfo{TokenSource: "none", APIKeySource: "ANTHROPIC_API_KEY"},
+			Extra:                 Extra{"pid": json.RawMessage("1234")},
+		}},
+		// Responses without a modeled payload are returned unchanged.
+		{ControlInterrupt, map[string]any{"still_queued": []any{}}, map[string]any{"still_queued": []any{}}},
+		{ControlSetModel, nil, nil},
+		{ControlInitialize, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.subtype), func(t *testing.T) {
+			got, err := ControlResponseBody{Subtype: "success", Response: tt.response}.DecodeResponse(tt.subtype)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeResponse() = %#v\n  want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMessage_NullableFields(t *testing.T) {
+	data := []byte(`{"type":"assistant","message":{"content":[],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":"end_turn","stop_sequence":"","type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*AssistantMessage)
+	if !ok {
+		t.Fatalf("expected *AssistantMessage, got %T", msg)
+	}
+	if m.ParentToolUseID.Valid {
+		t.Errorf("ParentToolUseID = %+v, want null", m.ParentToolUseID)
+	}
+	if got := m.Message.StopReason; !got.Valid || got.Value != "end_turn" {
+		t.Errorf("StopReason = %+v, want \"end_turn\"", got)
+	}
+	if got := m.Message.StopSequence; !got.Valid || got.Value != "" {
+		t.Errorf("StopSequence = %+v, want \"\"", got)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // DecodeContentBlock — each block type
 // ---------------------------------------------------------------------------
@@ -434,10 +589,14 @@ func TestDecodeMessage_UnknownSystemSubtype(t *testing.T) {
 }
 
 func TestDecodeMessage_UnknownResultSubtype(t *testing.T) {
+	// Result messages of any subtype decode, even without Lenient.
 	data := []byte(`{"type":"result","subtype":"unknown_sub"}`)
-	_, err := DecodeMessage(data)
-	if err == nil {
-		t.Fatal("expected error for unknown result subtype, got nil")
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := msg.(*ResultOtherMessage); !ok {
+		t.Errorf("expected *ResultOtherMessage, got %T", msg)
 	}
 }
 
@@ -465,6 +624,182 @@ func TestDecodeContentBlock_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestDecodeMessage_UnknownBlockInAssistant(t *testing.T) {
+	data := []byte(`{"type":"assistant","message":{"content":[{"type":"server_tool_use","id":"srv_001"}],"id":"msg_001","model":"claude-opus-4-6","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"s1","uuid":"u1"}`)
+	_, err := DecodeMessage(data)
+	if err == nil {
+		t.Fatal("expected error for unknown content block type, got nil")
+	}
+}
+
+func TestDecodeMessage_TextBlockInUserContent(t *testing.T) {
+	data := []byte(`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"[Request interrupted by user]"}]},"parent_tool_use_id":null,"session_id":"s1","uuid":"u1"}`)
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*UserContentMessage)
+	if !ok {
+		t.Fatalf("expected *UserContentMessage, got %T", msg)
+	}
+	if len(m.Message.Content) != 1 {
+		t.Fatalf("len(Content) = %d, want 1", len(m.Message.Content))
+	}
+	if tb, ok := m.Message.Content[0].(TextBlock); !ok || tb.Text != "[Request interrupted by user]" {
+		t.Errorf("Content[0] = %#v, want the interrupt text block", m.Message.Content[0])
+	}
+	if m.ParentToolUseID.Valid || m.SessionID != "s1" || m.UUID != "u1" {
+		t.Errorf("unexpected top-level fields: %+v", m)
+	}
+}
+
+func TestDecodeMessage_UnknownBlockInUserContent(t *testing.T) {
+	data := []byte(`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"},{"type":"image","source":{}}]},"parent_tool_use_id":null,"session_id":"s1","uuid":"u1"}`)
+	if _, err := DecodeMessage(data); err == nil {
+		t.Fatal("expected error for unknown block in user content array, got nil")
+	}
+}
+
+func TestDecodeMessage_ResultOtherMismatch(t *testing.T) {
+	data := []byte(`{"type":"result","subtype":"error_new","errors":[{"code":1}]}`)
+	_, err := DecodeMessage(data)
+	if err == nil {
+		t.Fatal("expected error for result that does not fit ResultOtherMessage, got nil")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Lenient mode
+// ---------------------------------------------------------------------------
+
+func TestDecodeMessage_LenientUnknownType(t *testing.T) {
+	data := []byte(`{"type":"hook_event","subtype":"started","hook":"PreToolUse"}`)
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*UnknownMessage)
+	if !ok {
+		t.Fatalf("expected *UnknownMessage, got %T", msg)
+	}
+	if m.Type != "hook_event" {
+		t.Errorf("Type = %q, want %q", m.Type, "hook_event")
+	}
+	if m.Subtype != "started" {
+		t.Errorf("Subtype = %q, want %q", m.Subtype, "started")
+	}
+	if string(m.Raw) != string(data) {
+		t.Errorf("Raw = %s, want %s", m.Raw, data)
+	}
+}
+
+func TestDecodeMessage_LenientUnknownSubtype(t *testing.T) {
+	for _, data := range []string{
+		`{"type":"system","subtype":"compact_boundary","compact_metadata":{"trigger":"auto"}}`,
+	} {
+		msg, err := DecodeMessage([]byte(data), Lenient())
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", data, err)
+		}
+		if _, ok := msg.(*UnknownMessage); !ok {
+			t.Errorf("expected *UnknownMessage for %s, got %T", data, msg)
+		}
+	}
+}
+
+func TestDecodeMessage_LenientKnownType(t *testing.T) {
+	data := []byte(`{"type":"user","message":{"role":"user","content":"say hello"}}`)
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := msg.(*UserTextMessage); !ok {
+		t.Fatalf("expected *UserTextMessage, got %T", msg)
+	}
+}
+
+func TestDecodeMessage_LenientUnknownBlockInAssistant(t *testing.T) {
+	data := []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"},{"type":"server_tool_use","id":"srv_001"}],"id":"msg_001","model":"claude-opus-4-6","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"s1","uuid":"u1"}`)
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*AssistantMessage)
+	if !ok {
+		t.Fatalf("expected *AssistantMessage, got %T", msg)
+	}
+	if len(m.Message.Content) != 2 {
+		t.Fatalf("len(Content) = %d, want 2", len(m.Message.Content))
+	}
+	ub, ok := m.Message.Content[1].(UnknownBlock)
+	if !ok {
+		t.Fatalf("Content[1] type = %T, want UnknownBlock", m.Message.Content[1])
+	}
+	if ub.Type != "server_tool_use" {
+		t.Errorf("UnknownBlock.Type = %q, want %q", ub.Type, "server_tool_use")
+	}
+}
+
+func TestDecodeMessage_LenientUserContent(t *testing.T) {
+	data := []byte(`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"},{"type":"image","source":{}}]},"parent_tool_use_id":null,"session_id":"s1","uuid":"u1"}`)
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*UserContentMessage)
+	if !ok {
+		t.Fatalf("expected *UserContentMessage, got %T", msg)
+	}
+	if len(m.Message.Content) != 2 {
+		t.Fatalf("len(Content) = %d, want 2", len(m.Message.Content))
+	}
+	if _, ok := m.Message.Content[0].(ToolResultBlock); !ok {
+		t.Errorf("Content[0] = %T, want ToolResultBlock", m.Message.Content[0])
+	}
+	if u, ok := m.Message.Content[1].(UnknownBlock); !ok || u.Type != "image" {
+		t.Errorf("Content[1] = %#v, want UnknownBlock of type image", m.Message.Content[1])
+	}
+}
+
+func TestDecodeMessage_LenientResultOtherMismatch(t *testing.T) {
+	data := []byte(`{"type":"result","subtype":"error_new","errors":[{"code":1}]}`)
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*UnknownMessage)
+	if !ok {
+		t.Fatalf("expected *UnknownMessage, got %T", msg)
+	}
+	if string(m.Raw) != string(data) {
+		t.Errorf("Raw = %s, want %s", m.Raw, data)
+	}
+}
+
+func TestDecodeContentBlock_LenientUnknownType(t *testing.T) {
+	data := []byte(`{"type":"redacted_thinking","data":"abc"}`)
+
+	block, err := DecodeContentBlock(data, Lenient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ub, ok := block.(UnknownBlock)
+	if !ok {
+		t.Fatalf("expected UnknownBlock, got %T", block)
+	}
+	if ub.Type != "redacted_thinking" {
+		t.Errorf("Type = %q, want %q", ub.Type, "redacted_thinking")
+	}
+	if string(ub.Raw) != string(data) {
+		t.Errorf("Raw = %s, want %s", ub.Raw, data)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // AssistantBody UnmarshalJSON — mixed content blocks
 // ---------------------------------------------------------------------------
@@ -494,3 +829,56 @@ func TestDecodeMessage_AssistantMixedContent(t *testing.T) {
 		t.Errorf("Content[2] type = %T, want ToolUseBlock", m.Message.Content[2])
 	}
 }
+
+func TestDecodeMessage_Usage(t *testing.T) {
+	data := []byte(`{"type":"result","subtype":"success","is_error":false,"duration_ms":281,"duration_api_ms":85,"num_turns":1,"result":"Hello!","stop_reason":"end_turn","session_id":"abc","total_cost_usd":0.00044,"usage":{"input_tokens":10,"cache_creation_input_tokens":3,"cache_read_input_tokens":5,"output_tokens":20,"server_tool_use":{"web_search_requests":1,"web_fetch_requests":0},"service_tier":"standard","cache_creation":{"ephemeral_1h_input_tokens":0,"ephemeral_5m_input_tokens":3},"speed":"standard"},"modelUsage":{"claude-sonnet-4-5-20250929":{"inputTokens":10,"outputTokens":20,"cacheReadInputTokens":5,"cacheCreationInputTokens":3,"webSearchRequests":1,"costUSD":0.00044,"contextWindow":200000,"maxOutputTokens":64000,"costBasis":"list"}},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx"}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := msg.(*ResultSuccessMessage)
+
+	u := m.Usage
+	if u.InputTokens != 10 || u.CacheCreationInputTokens != 3 || u.CacheReadInputTokens != 5 || u.OutputTokens != 20 {
+		t.Errorf("Usage counters = %+v", u)
+	}
+	if u.ServerToolUse == nil || u.ServerToolUse.WebSearchRequests != 1 {
+		t.Errorf("Usage.ServerToolUse = %+v, want web_search_requests 1", u.ServerToolUse)
+	}
+	if u.CacheCreation == nil || u.CacheCreation.Ephemeral5mInputTokens != 3 {
+		t.Errorf("Usage.CacheCreation = %+v, want ephemeral_5m_input_tokens 3", u.CacheCreation)
+	}
+	if u.ServiceTier != "standard" {
+		t.Errorf("Usage.ServiceTier = %q, want %q", u.ServiceTier, "standard")
+	}
+	if got := u.TotalInputTokens(); got != 18 {
+		t.Errorf("TotalInputTokens() = %d, want 18", got)
+	}
+	if got := u.TotalTokens(); got != 38 {
+		t.Errorf("TotalTokens() = %d, want 38", got)
+	}
+
+	mu, ok := m.ModelUsage["claude-sonnet-4-5-20250929"]
+	if !ok {
+		t.Fatalf("ModelUsage = %v, want entry for claude-sonnet-4-5-20250929", m.ModelUsage)
+	}
+	if mu.CostUSD != 0.00044 || mu.ContextWindow != 200000 || mu.MaxOutputTokens != 64000 {
+		t.Errorf("ModelUsage entry = %+v", mu)
+	}
+	if got := mu.TotalTokens(); got != 38 {
+		t.Errorf("ModelUsage.TotalTokens() = %d, want 38", got)
+	}
+
+	want := []string{"modelUsage.claude-sonnet-4-5-20250929.costBasis", "usage.speed"}
+	if got := UnknownFields(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnknownFields = %v, want %v", got, want)
+	}
+	got, err := EncodeMessage(m)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, data)
+	}
+}
@@ -0,0 +1,506 @@
+package ccprotocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// EncodeMessage encodes a message into the JSON form emitted by the CLI.
+// Nullable fields are written as explicit null and arrays that the CLI always
+// emits are written as [] when nil, so a decoded message re-encodes to the
+// same JSON it was decoded from.
+func EncodeMessage(m IsMessage) ([]byte, error) {
+	if m == nil {
+		return nil, errors.New("encode message: nil message")
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+	return b, nil
+}
+
+// MarshalJSON implements json.Marshaler for Nullable, emitting null when not Valid.
+func (n Nullable[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Value)
+}
+
+// MarshalJSON implements json.Marshaler for SystemInitMessage, emitting [] for nil lists.
+func (m SystemInitMessage) MarshalJSON() ([]byte, error) {
+	type Alias SystemInitMessage
+	m.Tools = emptyIfNil(m.Tools)
+	m.MCPServers = emptyIfNil(m.MCPServers)
+	m.SlashCommands = emptyIfNil(m.SlashCommands)
+	m.Agents = emptyIfNil(m.Agents)
+	m.Skills = emptyIfNil(m.Skills)
+	m.Plugins = emptyIfNil(m.Plugins)
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for AssistantBody, emitting content as [] when nil.
+func (b AssistantBody) MarshalJSON() ([]byte, error) {
+	type Alias AssistantBody
+	b.Content = emptyIfNil(b.Content)
+	return marshalExtra(Alias(b), b.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserToolResultMessage, emitting content as [] when nil.
+func (m UserToolResultMessage) MarshalJSON() ([]byte, error) {
+	type Alias UserToolResultMessage
+	m.Message.Content = emptyIfNil(m.Message.Content)
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserContentMessage, emitting content as [] when nil.
+func (m UserContentMessage) MarshalJSON() ([]byte, error) {
+	type Alias UserContentMessage
+	m.Message.Content = emptyIfNil(m.Message.Content)
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ResultSuccessMessage, emitting
+// modelUsage as {} and permission_denials as [] when nil.
+func (m ResultSuccessMessage) MarshalJSON() ([]byte, error) {
+	type Alias ResultSuccessMessage
+	m.ResultCommon = m.ResultCommon.orEmpty()
+	return marshalResult(Alias(m), m.Extra, "result", "stop_reason")
+}
+
+// MarshalJSON implements json.Marshaler for ResultErrorMessage, emitting
+// modelUsage as {} and permission_denials and errors as [] when nil.
+func (m ResultErrorMessage) MarshalJSON() ([]byte, error) {
+	type Alias ResultErrorMessage
+	m.ResultCommon = m.ResultCommon.orEmpty()
+	m.Errors = emptyIfNil(m.Errors)
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ResultMaxTurnsMessage, emitting
+// modelUsage as {} and permission_denials and errors as [] when nil.
+func (m ResultMaxTurnsMessage) MarshalJSON() ([]byte, error) {
+	type Alias ResultMaxTurnsMessage
+	m.ResultCommon = m.ResultCommon.orEmpty()
+	m.Errors = emptyIfNil(m.Errors)
+	return marshalResult(Alias(m), m.Extra, "stop_reason")
+}
+
+// MarshalJSON implements json.Marshaler for ResultOtherMessage, emitting
+// modelUsage as {} and permission_denials as [] when nil.
+func (m ResultOtherMessage) MarshalJSON() ([]byte, error) {
+	type Alias ResultOtherMessage
+	m.ResultCommon = m.ResultCommon.orEmpty()
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// orEmpty returns c with modelUsage and permission_denials set to empty
+// values in place of nil, as the CLI always emits them.
+func (c ResultCommon) orEmpty() ResultCommon {
+	c.ModelUsage = emptyMapIfNil(c.ModelUsage)
+	c.PermissionDenials = emptyIfNil(c.PermissionDenials)
+	return c
+}
+
+// marshalResult encodes v (an alias of a result type) like marshalExtra and
+// moves the given subtype-specific keys right after num_turns, where the CLI
+// emits them in the middle of the embedded ResultCommon fields.
+func marshalResult(v any, extra Extra, keys ...string) ([]byte, error) {
+	b, err := marshalExtra(v, extra)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := decodeObjectFields(b)
+	if err != nil {
+		return nil, err
+	}
+	var moved, rest []objectField
+	for _, f := range fields {
+		if slices.Contains(keys, f.Key) {
+			moved = append(moved, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+	at := slices.IndexFunc(rest, func(f objectField) bool { return f.Key == "num_turns" }) + 1
+	return encodeObjectFields(slices.Insert(rest, at, moved...)), nil
+}
+
+// MarshalJSON implements json.Marshaler for UnknownMessage, emitting the original JSON.
+func (m UnknownMessage) MarshalJSON() ([]byte, error) {
+	if m.Raw == nil {
+		return json.Marshal(m.MessageBase)
+	}
+	return m.Raw, nil
+}
+
+// MarshalJSON implements json.Marshaler for UnknownBlock, emitting the original JSON.
+func (b UnknownBlock) MarshalJSON() ([]byte, error) {
+	if b.Raw == nil {
+		return json.Marshal(b.ContentBlockBase)
+	}
+	return b.Raw, nil
+}
+
+// MarshalJSON implements json.Marshaler for UnknownControlRequest, emitting the original JSON.
+func (r UnknownControlRequest) MarshalJSON() ([]byte, error) {
+	if r.Raw == nil {
+		return json.Marshal(r.ControlRequestBase)
+	}
+	return r.Raw, nil
+}
+
+// MarshalJSON implements json.Marshaler for UnknownStreamEvent, emitting the original JSON.
+func (e UnknownStreamEvent) MarshalJSON() ([]byte, error) {
+	if e.Raw == nil {
+		return json.Marshal(e.StreamEventBase)
+	}
+	return e.Raw, nil
+}
+
+// ---------------------------------------------------------------------------
+// Unmodeled fields
+// ---------------------------------------------------------------------------
+
+// MarshalJSON implements json.Marshaler for SystemStatusMessage, emitting the fields in Extra.
+func (m SystemStatusMessage) MarshalJSON() ([]byte, error) {
+	type Alias SystemStatusMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for AssistantMessage, emitting the fields in Extra.
+func (m AssistantMessage) MarshalJSON() ([]byte, error) {
+	type Alias AssistantMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserTextMessage, emitting the fields in Extra.
+func (m UserTextMessage) MarshalJSON() ([]byte, error) {
+	type Alias UserTextMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for StreamEventMessage, emitting the fields in Extra.
+func (m StreamEventMessage) MarshalJSON() ([]byte, error) {
+	type Alias StreamEventMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserReplayMessage, emitting the fields in Extra.
+func (m UserReplayMessage) MarshalJSON() ([]byte, error) {
+	type Alias UserReplayMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ControlRequestMessage, emitting the fields in Extra.
+func (m ControlRequestMessage) MarshalJSON() ([]byte, error) {
+	type Alias ControlRequestMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ControlResponseMessage, emitting the fields in Extra.
+func (m ControlResponseMessage) MarshalJSON() ([]byte, error) {
+	type Alias ControlResponseMessage
+	return marshalExtra(Alias(m), m.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for TextBlock, emitting the fields in Extra.
+func (b TextBlock) MarshalJSON() ([]byte, error) {
+	type Alias TextBlock
+	return marshalExtra(Alias(b), b.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ToolUseBlock, emitting the fields in Extra.
+func (b ToolUseBlock) MarshalJSON() ([]byte, error) {
+	type Alias ToolUseBlock
+	return marshalExtra(Alias(b), b.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ThinkingBlock, emitting the fields in Extra.
+func (b ThinkingBlock) MarshalJSON() ([]byte, error) {
+	type Alias ThinkingBlock
+	return marshalExtra(Alias(b), b.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ToolResultBlock, emitting the fields in Extra.
+func (b ToolResultBlock) MarshalJSON() ([]byte, error) {
+	type Alias ToolResultBlock
+	return marshalExtra(Alias(b), b.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserTextBody, emitting the fields in Extra.
+func (v UserTextBody) MarshalJSON() ([]byte, error) {
+	type Alias UserTextBody
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserToolResultBody, emitting the fields in Extra.
+func (v UserToolResultBody) MarshalJSON() ([]byte, error) {
+	type Alias UserToolResultBody
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for UserContentBody, emitting the fields in Extra.
+func (v UserContentBody) MarshalJSON() ([]byte, error) {
+	type Alias UserContentBody
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for PermissionDenial, emitting the fields in Extra.
+func (v PermissionDenial) MarshalJSON() ([]byte, error) {
+	type Alias PermissionDenial
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for CanUseToolRequest, emitting the fields in Extra.
+func (r CanUseToolRequest) MarshalJSON() ([]byte, error) {
+	type Alias CanUseToolRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for SetPermissionModeRequest, emitting the fields in Extra.
+func (r SetPermissionModeRequest) MarshalJSON() ([]byte, error) {
+	type Alias SetPermissionModeRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for SetModelRequest, emitting the fields in Extra.
+func (r SetModelRequest) MarshalJSON() ([]byte, error) {
+	type Alias SetModelRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for InterruptRequest, emitting the fields in Extra.
+func (r InterruptRequest) MarshalJSON() ([]byte, error) {
+	type Alias InterruptRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for SetMaxThinkingTokensRequest, emitting the fields in Extra.
+func (r SetMaxThinkingTokensRequest) MarshalJSON() ([]byte, error) {
+	type Alias SetMaxThinkingTokensRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for InitializeRequest, emitting the fields in Extra.
+func (r InitializeRequest) MarshalJSON() ([]byte, error) {
+	type Alias InitializeRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for HookCallbackRequest, emitting the fields in Extra.
+func (r HookCallbackRequest) MarshalJSON() ([]byte, error) {
+	type Alias HookCallbackRequest
+	return marshalExtra(Alias(r), r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for HookMatcher, emitting the fields in Extra.
+func (v HookMatcher) MarshalJSON() ([]byte, error) {
+	type Alias HookMatcher
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for AgentDefinition, emitting the fields in Extra.
+func (v AgentDefinition) MarshalJSON() ([]byte, error) {
+	type Alias AgentDefinition
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for PermissionUpdate, emitting the fields in Extra.
+func (v PermissionUpdate) MarshalJSON() ([]byte, error) {
+	type Alias PermissionUpdate
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for PermissionRuleValue, emitting the fields in Extra.
+func (v PermissionRuleValue) MarshalJSON() ([]byte, error) {
+	type Alias PermissionRuleValue
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for SetPermissionModeResponse, emitting the fields in Extra.
+func (v SetPermissionModeResponse) MarshalJSON() ([]byte, error) {
+	type Alias SetPermissionModeResponse
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for InitializeResponse, emitting the fields in Extra.
+func (v InitializeResponse) MarshalJSON() ([]byte, error) {
+	type Alias InitializeResponse
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for SlashCommand, emitting the fields in Extra.
+func (v SlashCommand) MarshalJSON() ([]byte, error) {
+	type Alias SlashCommand
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for AgentInfo, emitting the fields in Extra.
+func (v AgentInfo) MarshalJSON() ([]byte, error) {
+	type Alias AgentInfo
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ModelInfo, emitting the fields in Extra.
+func (v ModelInfo) MarshalJSON() ([]byte, error) {
+	type Alias ModelInfo
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for AccountInfo, emitting the fields in Extra.
+func (v AccountInfo) MarshalJSON() ([]byte, error) {
+	type Alias AccountInfo
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ControlResponseBody, emitting the fields in Extra.
+func (v ControlResponseBody) MarshalJSON() ([]byte, error) {
+	type Alias ControlResponseBody
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for Usage, emitting the fields in Extra.
+func (v Usage) MarshalJSON() ([]byte, error) {
+	type Alias Usage
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ServerToolUse, emitting the fields in Extra.
+func (v ServerToolUse) MarshalJSON() ([]byte, error) {
+	type Alias ServerToolUse
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for CacheCreation, emitting the fields in Extra.
+func (v CacheCreation) MarshalJSON() ([]byte, error) {
+	type Alias CacheCreation
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ModelUsage, emitting the fields in Extra.
+func (v ModelUsage) MarshalJSON() ([]byte, error) {
+	type Alias ModelUsage
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for MessageStartEvent, emitting the fields in Extra.
+func (e MessageStartEvent) MarshalJSON() ([]byte, error) {
+	type Alias MessageStartEvent
+	return marshalExtra(Alias(e), e.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ContentBlockStartEvent, emitting the fields in Extra.
+func (e ContentBlockStartEvent) MarshalJSON() ([]byte, error) {
+	type Alias ContentBlockStartEvent
+	return marshalExtra(Alias(e), e.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ContentBlockDeltaEvent, emitting the fields in Extra.
+func (e ContentBlockDeltaEvent) MarshalJSON() ([]byte, error) {
+	type Alias ContentBlockDeltaEvent
+	return marshalExtra(Alias(e), e.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ContentBlockStopEvent, emitting the fields in Extra.
+func (e ContentBlockStopEvent) MarshalJSON() ([]byte, error) {
+	type Alias ContentBlockStopEvent
+	return marshalExtra(Alias(e), e.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for MessageDeltaEvent, emitting the fields in Extra.
+func (e MessageDeltaEvent) MarshalJSON() ([]byte, error) {
+	type Alias MessageDeltaEvent
+	return marshalExtra(Alias(e), e.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for MessageStopEvent, emitting the fields in Extra.
+func (e MessageStopEvent) MarshalJSON() ([]byte, error) {
+	type Alias MessageStopEvent
+	return marshalExtra(Alias(e), e.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for ContentDelta, emitting the fields in Extra.
+func (v ContentDelta) MarshalJSON() ([]byte, error) {
+	type Alias ContentDelta
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// MarshalJSON implements json.Marshaler for MessageDelta, emitting the fields in Extra.
+func (v MessageDelta) MarshalJSON() ([]byte, error) {
+	type Alias MessageDelta
+	return marshalExtra(Alias(v), v.Extra)
+}
+
+// emptyIfNil returns an empty slice in place of nil so it encodes as [].
+func emptyIfNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// emptyMapIfNil returns an empty map in place of nil so it encodes as {}.
+func emptyMapIfNil[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return map[K]V{}
+	}
+	return m
+}
+
+// objectField is a single key/value pair of a JSON object.
+type objectField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeObjectFields splits a JSON object into its key/value pairs,
+// preserving the order in which they appear.
+func decodeObjectFields(data []byte) ([]objectField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, fmt.Errorf("expected JSON object, got %v", tok)
+	}
+	var fields []objectField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, objectField{Key: key, Value: value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// encodeObjectFields joins key/value pairs back into a compact JSON object.
+func encodeObjectFields(fields []objectField) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(f.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
@@ -0,0 +1,205 @@
+package ccprotocol_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// ---------------------------------------------------------------------------
+// EncodeMessage — round trip over protocol.go examples
+// ---------------------------------------------------------------------------
+
+// docExamples returns every ```json example in the type doc comments of
+// protocol.go, keyed by "TypeName#n".
+func docExamples(t *testing.T) map[string]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "protocol.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse protocol.go: %v", err)
+	}
+	examples := map[string]string{}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE || gd.Doc == nil {
+			continue
+		}
+		name := gd.Specs[0].(*ast.TypeSpec).Name.Name
+		inBlock := false
+		n := 0
+		for _, line := range strings.Split(gd.Doc.Text(), "\n") {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case trimmed == "```json":
+				inBlock = true
+			case trimmed == "```":
+				inBlock = false
+			case inBlock && trimmed != "":
+				n++
+				examples[fmt.Sprintf("%s#%d", name, n)] = trimmed
+			}
+		}
+	}
+	return examples
+}
+
+// unorderedExamples lists examples containing free-form maps, which are
+// re-encoded with sorted keys and therefore only compared as JSON values.
+var unorderedExamples = map[string]bool{
+	"StreamEventMessage#1": true, // event
+}
+
+func TestEncodeMessage_RoundTripDocExamples(t *testing.T) {
+	examples := docExamples(t)
+	if len(examples) == 0 {
+		t.Fatal("no examples found in protocol.go")
+	}
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			msg, err := DecodeMessage([]byte(example))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got, err := EncodeMessage(msg)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if unorderedExamples[name] {
+				if !jsonEqual(t, got, []byte(example)) {
+					t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, example)
+				}
+				return
+			}
+			if string(got) != example {
+				t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, example)
+			}
+		})
+	}
+}
+
+// jsonEqual reports whether a and b hold the same JSON value.
+// Unlike a map comparison after omitempty decoding, null and missing keys differ.
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// ---------------------------------------------------------------------------
+// EncodeMessage — wire rules
+// ---------------------------------------------------------------------------
+
+func TestEncodeMessage_ExplicitNulls(t *testing.T) {
+	got, err := EncodeMessage(&AssistantMessage{
+		MessageBase: MessageBase{Type: TypeAssistant},
+		Message: AssistantBody{
+			ID:       "msg_001",
+			Role:     RoleAssistant,
+			BodyType: AssistantBodyTypeMessage,
+		},
+		SessionID: "abc",
+		UUID:      "xxx",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"type":"assistant","message":{"content":[],"id":"msg_001","model":"","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":0,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":0}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}`
+	if string(got) != want {
+		t.Errorf("EncodeMessage =\n  %s\nwant\n  %s", got, want)
+	}
+}
+
+func TestEncodeMessage_StatusNull(t *testing.T) {
+	got, err := EncodeMessage(&SystemStatusMessage{
+		MessageBase:    MessageBase{Type: TypeSystem, Subtype: SubtypeStatus},
+		PermissionMode: PermissionPlan,
+		UUID:           "xxx",
+		SessionID:      "abc",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"type":"system","subtype":"status","status":null,"permissionMode":"plan","uuid":"xxx","session_id":"abc"}`
+	if string(got) != want {
+		t.Errorf("EncodeMessage =\n  %s\nwant\n  %s", got, want)
+	}
+}
+
+func TestEncodeMessage_EmptyArrays(t *testing.T) {
+	got, err := EncodeMessage(&ResultErrorMessage{
+		MessageBase: MessageBase{Type: TypeResult, Subtype: SubtypeErrorDuringExecution},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`"permission_denials":[]`, `"errors":[]`} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("EncodeMessage = %s, want it to contain %s", got, want)
+		}
+	}
+}
+
+func TestEncodeMessage_StopReasonString(t *testing.T) {
+	got, err := EncodeMessage(&ResultSuccessMessage{
+		MessageBase: MessageBase{Type: TypeResult, Subtype: SubtypeSuccess},
+		StopReason:  NewNullable("end_turn"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(got), `"stop_reason":"end_turn"`) {
+		t.Errorf("EncodeMessage = %s, want stop_reason \"end_turn\"", got)
+	}
+}
+
+func TestEncodeMessage_NullVersusEmptyString(t *testing.T) {
+	data := []byte(`{"type":"system","subtype":"status","status":"","permissionMode":"plan","uuid":"xxx","session_id":"abc"}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got, err := EncodeMessage(msg)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, data)
+	}
+}
+
+func TestEncodeMessage_Unknown(t *testing.T) {
+	data := []byte(`{"type":"assistant","message":{"content":[{"type":"server_tool_use","id":"srv_001","name":"web_search"}],"id":"msg_001","model":"claude-opus-4-6","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":1,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"s1","uuid":"u1"}`)
+
+	msg, err := DecodeMessage(data, Lenient())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got, err := EncodeMessage(msg)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, data)
+	}
+}
+
+func TestEncodeMessage_Nil(t *testing.T) {
+	if _, err := EncodeMessage(nil); err == nil {
+		t.Fatal("expected error for nil message, got nil")
+	}
+}
@@ -0,0 +1,178 @@
+package ccprotocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Extra holds JSON fields that are not modeled by a protocol type.
+// It is populated on decode and re-emitted after the known fields on encode.
+// Encoding keeps the fields but not the input's key order: known fields come
+// in struct order, followed by the extra fields sorted by key.
+type Extra map[string]json.RawMessage
+
+var knownFieldsCache sync.Map // reflect.Type -> map[string]bool
+
+// knownFields returns the set of JSON keys modeled by the struct type t,
+// including keys promoted from embedded structs.
+func knownFields(t reflect.Type) map[string]bool {
+	if v, ok := knownFieldsCache.Load(t); ok {
+		return v.(map[string]bool)
+	}
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k := range knownFields(f.Type) {
+				known[k] = true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		known[name] = true
+	}
+	knownFieldsCache.Store(t, known)
+	return known
+}
+
+// unmarshalExtra decodes data into v (a pointer to an alias of the protocol
+// type) and stores fields not modeled by v in extra.
+func unmarshalExtra(data []byte, v any, extra *Extra) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	return decodeExtra(data, reflect.TypeOf(v).Elem(), extra)
+}
+
+// decodeExtra stores the fields of the JSON object data that are not
+// modeled by the struct type t in extra.
+func decodeExtra(data []byte, t reflect.Type, extra *Extra) error {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	known := knownFields(t)
+	*extra = nil
+	for k, v := range all {
+		if known[k] {
+			continue
+		}
+		if *extra == nil {
+			*extra = Extra{}
+		}
+		(*extra)[k] = v
+	}
+	return nil
+}
+
+// marshalExtra encodes v (an alias of the protocol type) and appends the
+// fields in extra, sorted by key, after the known fields.
+func marshalExtra(v any, extra Extra) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+	known := knownFields(reflect.TypeOf(v))
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		if !known[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := b[:len(b)-1] // drop the closing brace
+	for _, k := range keys {
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		key, _ := json.Marshal(k)
+		out = append(out, key...)
+		out = append(out, ':')
+		out = append(out, extra[k]...)
+	}
+	return append(out, '}'), nil
+}
+
+// UnknownFields returns the dot-separated paths of all fields in v that were
+// not modeled by the protocol types, e.g. "message.context_management" or
+// "message.content.0.citations". v is typically a decoded message. The result
+// is sorted and is empty when the input matched the protocol types exactly.
+func UnknownFields(v any) []string {
+	var paths []string
+	collectUnknownFields(reflect.ValueOf(v), "", &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+var extraType = reflect.TypeOf(Extra(nil))
+
+func collectUnknownFields(v reflect.Value, path string, paths *[]string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectUnknownFields(v.Elem(), path, paths)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectUnknownFields(v.Index(i), joinPath(path, strconv.Itoa(i)), paths)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			collectUnknownFields(iter.Value(), joinPath(path, iter.Key().String()), paths)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			if f.Type == extraType {
+				for k := range fv.Interface().(Extra) {
+					*paths = append(*paths, joinPath(path, k))
+				}
+				continue
+			}
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" {
+				collectUnknownFields(fv, path, paths)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			collectUnknownFields(fv, joinPath(path, name), paths)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
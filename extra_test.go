@@ -0,0 +1,122 @@
+package ccprotocol_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// ---------------------------------------------------------------------------
+// Extra — unmodeled fields survive decoding and re-encoding
+// ---------------------------------------------------------------------------
+
+func TestDecodeMessage_Extra(t *testing.T) {
+	data := []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"Hello!","citations":null}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1},"context_management":null},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx","timestamp":"2026-01-01T00:00:00.000Z"}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*AssistantMessage)
+	if !ok {
+		t.Fatalf("expected *AssistantMessage, got %T", msg)
+	}
+	if got := string(m.Extra["timestamp"]); got != `"2026-01-01T00:00:00.000Z"` {
+		t.Errorf("Extra[timestamp] = %s, want %q", got, "2026-01-01T00:00:00.000Z")
+	}
+	if len(m.Extra) != 1 {
+		t.Errorf("len(Extra) = %d, want 1: %v", len(m.Extra), m.Extra)
+	}
+	if got := string(m.Message.Extra["context_management"]); got != "null" {
+		t.Errorf("Message.Extra[context_management] = %s, want null", got)
+	}
+	tb := m.Message.Content[0].(TextBlock)
+	if got := string(tb.Extra["citations"]); got != "null" {
+		t.Errorf("TextBlock.Extra[citations] = %s, want null", got)
+	}
+}
+
+func TestDecodeMessage_NoExtra(t *testing.T) {
+	data := []byte(`{"type":"system","subtype":"status","status":null,"permissionMode":"plan","uuid":"u2","session_id":"s2"}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m := msg.(*SystemStatusMessage); m.Extra != nil {
+		t.Errorf("Extra = %v, want nil", m.Extra)
+	}
+}
+
+func TestEncodeMessage_Extra(t *testing.T) {
+	data := []byte(`{"type":"result","subtype":"success","is_error":false,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"Hello!","stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1,"inference_geo":"","speed":"standard"},"modelUsage":{"claude-sonnet-4-5-20250929":{"inputTokens":10,"outputTokens":1,"cacheReadInputTokens":0,"cacheCreationInputTokens":0,"webSearchRequests":0,"costUSD":0.00055,"contextWindow":200000,"maxOutputTokens":64000,"costBasis":"list"}},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx","terminal_reason":"completed","ttft_ms":214}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got, err := EncodeMessage(msg)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	// Unmodeled fields are emitted after the modeled ones, sorted by key;
+	// the input already lists them that way, so the round trip is exact.
+	if string(got) != string(data) {
+		t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, data)
+	}
+}
+
+func TestEncodeMessage_ExtraOrder(t *testing.T) {
+	// The input's key order is not kept: modeled fields come first in struct
+	// order, then the unmodeled ones sorted by key.
+	data := []byte(`{"zeta":1,"type":"system","uuid":"u2","alpha":true,"subtype":"status","status":null,"session_id":"s2","permissionMode":"plan","mid":"x"}`)
+	want := `{"type":"system","subtype":"status","status":null,"permissionMode":"plan","uuid":"u2","session_id":"s2","alpha":true,"mid":"x","zeta":1}`
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got, err := EncodeMessage(msg)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("encode mismatch:\n  got:  %s\n  want: %s", got, want)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// UnknownFields
+// ---------------------------------------------------------------------------
+
+func TestUnknownFields(t *testing.T) {
+	data := []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"a"},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"},"caller":{"type":"direct"}}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":1,"speed":"standard"},"context_management":null},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx","timestamp":"t"}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := UnknownFields(msg)
+	want := []string{
+		"message.content.1.caller",
+		"message.context_management",
+		"message.usage.speed",
+		"timestamp",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnknownFields = %v, want %v", got, want)
+	}
+}
+
+func TestUnknownFields_None(t *testing.T) {
+	for name, example := range docExamples(t) {
+		msg, err := DecodeMessage([]byte(example))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if got := UnknownFields(msg); len(got) != 0 {
+			t.Errorf("%s: UnknownFields = %v, want none", name, got)
+		}
+	}
+}
@@ -44,7 +44,7 @@ func defaultAssistantPattern(opts ...func(*AssistantMessage)) utils.Pattern {
 			Model:    "claude-sonnet-4-5-20250929",
 			Role:     RoleAssistant,
 			BodyType: AssistantBodyTypeMessage,
-			Usage:    map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)},
+			Usage:    Usage{InputTokens: 10, OutputTokens: 1},
 		},
 		SessionID: "session-abc123",
 		UUID:      "uuid-abc123",
@@ -60,18 +60,20 @@ func defaultAssistantPattern(opts ...func(*AssistantMessage)) utils.Pattern {
 // defaultResultPattern returns a ResultSuccessMessage JSON assertion pattern
 func defaultResultPattern(opts ...func(*ResultSuccessMessage)) utils.Pattern {
 	m := ResultSuccessMessage{
-		MessageBase:       MessageBase{Type: TypeResult, Subtype: SubtypeSuccess},
-		DurationMs:        100,
-		DurationApiMs:     50,
-		NumTurns:          1,
-		Result:            "Hello!",
-		SessionID:         "session-abc123",
-		TotalCostUSD:      0.001,
-		UUID:              "uuid-abc123",
-		Usage:             map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)},
-		ModelUsage:        map[string]any{"claude-sonnet-4-5-20250929": map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)}},
-		PermissionDenials: []PermissionDenial{},
-		FastModeState:     FastModeOff,
+		MessageBase: MessageBase{Type: TypeResult, Subtype: SubtypeSuccess},
+		ResultCommon: ResultCommon{
+			DurationMs:        100,
+			DurationApiMs:     50,
+			NumTurns:          1,
+			SessionID:         "session-abc123",
+			TotalCostUSD:      0.001,
+			UUID:              "uuid-abc123",
+			Usage:             Usage{InputTokens: 10, OutputTokens: 1},
+			ModelUsage:        map[string]ModelUsage{"claude-sonnet-4-5-20250929": {InputTokens: 10, OutputTokens: 1, CostUSD: 0.001, ContextWindow: 200000, MaxOutputTokens: 64000}},
+			PermissionDenials: []PermissionDenial{},
+			FastModeState:     FastModeOff,
+		},
+		Result: "Hello!",
 	}
 	for _, o := range opts {
 		o(&m)
@@ -99,20 +101,38 @@ func defaultUserToolResultPattern(opts ...func(*UserToolResultMessage)) utils.Pa
 	)
 }
 
+// defaultUserContentPattern returns a UserContentMessage JSON assertion pattern
+func defaultUserContentPattern(opts ...func(*UserContentMessage)) utils.Pattern {
+	m := UserContentMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		SessionID:   "session-abc123",
+		UUID:        "uuid-abc123",
+		Message:     UserContentBody{Role: RoleUser},
+	}
+	for _, o := range opts {
+		o(&m)
+	}
+	return utils.NewPattern(utils.MustJSONVersioned(m),
+		"session_id", "uuid",
+	)
+}
+
 // defaultResultErrorPattern returns a ResultErrorMessage JSON assertion pattern
 func defaultResultErrorPattern(opts ...func(*ResultErrorMessage)) utils.Pattern {
 	m := ResultErrorMessage{
-		MessageBase:       MessageBase{Type: TypeResult, Subtype: SubtypeErrorDuringExecution},
-		DurationMs:        100,
-		DurationApiMs:     50,
-		NumTurns:          1,
-		SessionID:         "session-abc123",
-		TotalCostUSD:      0,
-		UUID:              "uuid-abc123",
-		Usage:             map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)},
-		ModelUsage:        map[string]any{"claude-sonnet-4-5-20250929": map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)}},
-		PermissionDenials: []PermissionDenial{},
-		FastModeState:     FastModeOff,
+		MessageBase: MessageBase{Type: TypeResult, Subtype: SubtypeErrorDuringExecution},
+		ResultCommon: ResultCommon{
+			DurationMs:        100,
+			DurationApiMs:     50,
+			NumTurns:          1,
+			SessionID:         "session-abc123",
+			TotalCostUSD:      0,
+			UUID:              "uuid-abc123",
+			Usage:             Usage{InputTokens: 10, OutputTokens: 1},
+			ModelUsage:        map[string]ModelUsage{"claude-sonnet-4-5-20250929": {InputTokens: 10, OutputTokens: 1, CostUSD: 0.001, ContextWindow: 200000, MaxOutputTokens: 64000}},
+			PermissionDenials: []PermissionDenial{},
+			FastModeState:     FastModeOff,
+		},
 	}
 	for _, o := range opts {
 		o(&m)
@@ -126,18 +146,20 @@ func defaultResultErrorPattern(opts ...func(*ResultErrorMessage)) utils.Pattern
 // defaultResultMaxTurnsPattern returns a ResultMaxTurnsMessage JSON assertion pattern
 func defaultResultMaxTurnsPattern(opts ...func(*ResultMaxTurnsMessage)) utils.Pattern {
 	m := ResultMaxTurnsMessage{
-		MessageBase:       MessageBase{Type: TypeResult, Subtype: SubtypeErrorMaxTurns},
-		DurationMs:        100,
-		DurationApiMs:     50,
-		NumTurns:          1,
-		SessionID:         "session-abc123",
-		TotalCostUSD:      0.001,
-		UUID:              "uuid-abc123",
-		Usage:             map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)},
-		ModelUsage:        map[string]any{"claude-sonnet-4-5-20250929": map[string]any{"input_tokens": float64(10), "output_tokens": float64(1)}},
-		PermissionDenials: []PermissionDenial{},
-		FastModeState:     FastModeOff,
-		Errors:            []string{},
+		MessageBase: MessageBase{Type: TypeResult, Subtype: SubtypeErrorMaxTurns},
+		ResultCommon: ResultCommon{
+			DurationMs:        100,
+			DurationApiMs:     50,
+			NumTurns:          1,
+			SessionID:         "session-abc123",
+			TotalCostUSD:      0.001,
+			UUID:              "uuid-abc123",
+			Usage:             Usage{InputTokens: 10, OutputTokens: 1},
+			ModelUsage:        map[string]ModelUsage{"claude-sonnet-4-5-20250929": {InputTokens: 10, OutputTokens: 1, CostUSD: 0.001, ContextWindow: 200000, MaxOutputTokens: 64000}},
+			PermissionDenials: []PermissionDenial{},
+			FastModeState:     FastModeOff,
+		},
+		Errors: []string{},
 	}
 	for _, o := range opts {
 		o(&m)
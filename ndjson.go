@@ -0,0 +1,128 @@
+package ccprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LineError records an error and the 1-based line number it occurred on.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads newline-delimited messages as emitted by the CLI with
+// --output-format stream-json. Lines may be of any length; blank lines are
+// skipped.
+type Reader struct {
+	r    *bufio.Reader
+	opts []DecodeOption
+	line int
+}
+
+// NewReader returns a Reader that reads from r and decodes each line with
+// DecodeMessage using opts.
+func NewReader(r io.Reader, opts ...DecodeOption) *Reader {
+	return &Reader{r: bufio.NewReader(r), opts: opts}
+}
+
+// ReadRaw returns the next non-blank line without decoding it.
+// It returns io.EOF when the input is exhausted.
+func (r *Reader) ReadRaw() (json.RawMessage, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		r.line++
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return json.RawMessage(line), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Read returns the next message decoded with DecodeMessage.
+// Decode errors are returned as *LineError. It returns io.EOF when the input
+// is exhausted.
+func (r *Reader) Read() (IsMessage, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := DecodeMessage(raw, r.opts...)
+	if err != nil {
+		return nil, &LineError{Line: r.line, Err: err}
+	}
+	return msg, nil
+}
+
+// Line returns the line number of the line most recently returned.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Writer writes newline-delimited input messages for the CLI's
+// --input-format stream-json. It is safe for concurrent use; each message
+// is written with a single Write call.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write encodes m and writes it followed by a newline. Only messages the CLI
+// accepts on stdin are allowed: user (text), control_request and
+// control_response.
+func (w *Writer) Write(m IsMessage) error {
+	switch m.(type) {
+	case *UserTextMessage, UserTextMessage,
+		*ControlRequestMessage, ControlRequestMessage,
+		*ControlResponseMessage, ControlResponseMessage:
+	default:
+		return fmt.Errorf("write message: %T is not an input message", m)
+	}
+	b, err := EncodeMessage(m)
+	if err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return w.WriteRaw(b)
+}
+
+// WriteRaw writes a single pre-encoded JSON line followed by a newline.
+// The line must not itself contain a newline.
+func (w *Writer) WriteRaw(line []byte) error {
+	if bytes.IndexByte(line, '\n') >= 0 {
+		return errors.New("write message: line contains a newline")
+	}
+	buf := make([]byte, 0, len(line)+1)
+	buf = append(buf, line...)
+	buf = append(buf, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.w.Write(buf); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return nil
+}
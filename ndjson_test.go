@@ -0,0 +1,169 @@
+package ccprotocol_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// ---------------------------------------------------------------------------
+// Reader
+// ---------------------------------------------------------------------------
+
+func TestReader_Read(t *testing.T) {
+	input := `{"type":"system","subtype":"status","status":null,"permissionMode":"plan","uuid":"u1","session_id":"s1"}
+
+{"type":"user","message":{"role":"user","content":"hello"}}
+{"type":"result","subtype":"success","is_error":false,"duration_ms":1,"duration_api_ms":1,"num_turns":1,"result":"ok","stop_reason":null,"session_id":"s1","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"u2"}`
+
+	r := NewReader(strings.NewReader(input))
+	var got []IsMessage
+	for {
+		msg, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, msg)
+	}
+	if len(got) != 3 {
+		t.Fatalf("read %d messages, want 3", len(got))
+	}
+	if _, ok := got[0].(*SystemStatusMessage); !ok {
+		t.Errorf("got[0] type = %T, want *SystemStatusMessage", got[0])
+	}
+	if _, ok := got[1].(*UserTextMessage); !ok {
+		t.Errorf("got[1] type = %T, want *UserTextMessage", got[1])
+	}
+	if _, ok := got[2].(*ResultSuccessMessage); !ok {
+		t.Errorf("got[2] type = %T, want *ResultSuccessMessage", got[2])
+	}
+	if r.Line() != 4 {
+		t.Errorf("Line() = %d, want 4", r.Line())
+	}
+}
+
+func TestReader_LargeLine(t *testing.T) {
+	// Larger than the 1 MiB bufio.Scanner buffer the harness used to have.
+	content := strings.Repeat("x", 4*1024*1024)
+	input := `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_001","content":"` + content + `"}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx","tool_use_result":{}}` + "\n"
+
+	msg, err := NewReader(strings.NewReader(input)).Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*UserToolResultMessage)
+	if !ok {
+		t.Fatalf("expected *UserToolResultMessage, got %T", msg)
+	}
+	if got := m.Message.Content[0].Content.(string); len(got) != len(content) {
+		t.Errorf("len(Content) = %d, want %d", len(got), len(content))
+	}
+}
+
+func TestReader_LineError(t *testing.T) {
+	input := `{"type":"user","message":{"role":"user","content":"hello"}}
+{"type":"unknown_type"}
+`
+	r := NewReader(strings.NewReader(input))
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := r.Read()
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) {
+		t.Fatalf("expected *LineError, got %v", err)
+	}
+	if lineErr.Line != 2 {
+		t.Errorf("Line = %d, want 2", lineErr.Line)
+	}
+}
+
+func TestReader_Lenient(t *testing.T) {
+	r := NewReader(strings.NewReader(`{"type":"unknown_type"}`), Lenient())
+	msg, err := r.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := msg.(*UnknownMessage); !ok {
+		t.Errorf("expected *UnknownMessage, got %T", msg)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Writer
+// ---------------------------------------------------------------------------
+
+func TestWriter_Write(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.Write(&UserTextMessage{
+		MessageBase: MessageBase{Type: TypeUser},
+		Message:     UserTextBody{Role: RoleUser, Content: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"type":"user","message":{"role":"user","content":"hello"}}` + "\n"
+	if buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriter_RejectsOutputMessage(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewWriter(&buf).Write(&ResultSuccessMessage{
+		MessageBase: MessageBase{Type: TypeResult, Subtype: SubtypeSuccess},
+	})
+	if err == nil {
+		t.Fatal("expected error for output message, got nil")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q, want nothing", buf.String())
+	}
+}
+
+func TestWriter_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.Write(&ControlRequestMessage{
+				MessageBase: MessageBase{Type: TypeControlRequest},
+				RequestID:   "req",
+				Request:     InterruptRequest{ControlRequestBase{Subtype: ControlInterrupt}},
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	r := NewReader(&buf)
+	n := 0
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		n++
+	}
+	if n != 50 {
+		t.Errorf("read %d messages, want 50", n)
+	}
+}
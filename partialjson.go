@@ -0,0 +1,278 @@
+package ccprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParsePartialJSON parses a prefix of a JSON object, such as the
+// partial_json fragments of a streaming tool_use block joined so far, and
+// returns the fields received so far. Unterminated strings, objects and
+// arrays are kept with the content received so far; keys without a value
+// and unterminated numbers and literals are dropped. An empty input gives an
+// empty map. It returns an error if s is not a prefix of a JSON object.
+func ParsePartialJSON(s string) (map[string]any, error) {
+	p := &partialParser{s: s}
+	p.skipSpace()
+	if p.eof() {
+		return map[string]any{}, nil
+	}
+	if p.s[p.i] != '{' {
+		return nil, p.unexpected()
+	}
+	v, _, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.unexpected()
+	}
+	return v.(map[string]any), nil
+}
+
+// partialParser is a recursive descent parser that treats the end of input
+// as the end of every open value.
+type partialParser struct {
+	s string
+	i int
+}
+
+func (p *partialParser) eof() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *partialParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *partialParser) unexpected() error {
+	return fmt.Errorf("partial json: unexpected %q at offset %d", p.s[p.i], p.i)
+}
+
+// value parses the value at the current offset. ok is false when the input
+// ends before the value is usable.
+func (p *partialParser) value() (v any, ok bool, err error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, false, nil
+	}
+	switch c := p.s[p.i]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		s, _, err := p.string()
+		return s, err == nil, err
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	default:
+		return p.literal()
+	}
+}
+
+func (p *partialParser) object() (any, bool, error) {
+	obj := map[string]any{}
+	p.i++ // '{'
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return obj, true, nil
+		}
+		if p.s[p.i] == '}' && len(obj) == 0 {
+			p.i++
+			return obj, true, nil
+		}
+		if p.s[p.i] != '"' {
+			return nil, false, p.unexpected()
+		}
+		k, closed, err := p.string()
+		if err != nil {
+			return nil, false, err
+		}
+		if !closed {
+			return obj, true, nil
+		}
+		p.skipSpace()
+		if p.eof() {
+			return obj, true, nil
+		}
+		if p.s[p.i] != ':' {
+			return nil, false, p.unexpected()
+		}
+		p.i++
+		v, ok, err := p.value()
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			obj[k] = v
+		}
+		p.skipSpace()
+		if p.eof() {
+			return obj, true, nil
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case '}':
+			p.i++
+			return obj, true, nil
+		default:
+			return nil, false, p.unexpected()
+		}
+	}
+}
+
+func (p *partialParser) array() (any, bool, error) {
+	arr := []any{}
+	p.i++ // '['
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return arr, true, nil
+		}
+		if p.s[p.i] == ']' && len(arr) == 0 {
+			p.i++
+			return arr, true, nil
+		}
+		v, ok, err := p.value()
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			arr = append(arr, v)
+		}
+		p.skipSpace()
+		if p.eof() {
+			return arr, true, nil
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case ']':
+			p.i++
+			return arr, true, nil
+		default:
+			return nil, false, p.unexpected()
+		}
+	}
+}
+
+// string parses a string and reports whether it was terminated. An
+// unterminated string yields the characters received so far, without an
+// incomplete escape sequence, a high surrogate escape still waiting for its
+// low surrogate, or an incomplete UTF-8 sequence at the end.
+func (p *partialParser) string() (string, bool, error) {
+	start := p.i
+	p.i++ // '"'
+	for !p.eof() {
+		switch p.s[p.i] {
+		case '\\':
+			p.i += 2
+			continue
+		case '"':
+			p.i++
+			var s string
+			if err := json.Unmarshal([]byte(p.s[start:p.i]), &s); err != nil {
+				return "", false, fmt.Errorf("partial json: string at offset %d: %w", start, err)
+			}
+			return s, true, nil
+		}
+		p.i++
+	}
+	p.i = len(p.s)
+
+	raw := p.s[start:]
+	// Cutting an incomplete low surrogate escape can leave its high
+	// surrogate at the end, hence the loop.
+	for {
+		j := strings.LastIndex(raw, `\`)
+		if j < 0 || escaped(raw, j) || !escapeIncomplete(raw[j:]) && !highSurrogate(raw[j:]) {
+			break
+		}
+		raw = raw[:j]
+	}
+	for n := 0; n < utf8.UTFMax-1 && len(raw) > 1; n++ {
+		if r, size := utf8.DecodeLastRuneInString(raw); r != utf8.RuneError || size != 1 {
+			break
+		}
+		raw = raw[:len(raw)-1]
+	}
+	var s string
+	if err := json.Unmarshal([]byte(raw+`"`), &s); err != nil {
+		return "", false, fmt.Errorf("partial json: string at offset %d: %w", start, err)
+	}
+	return s, false, nil
+}
+
+// escapeIncomplete reports whether esc, which starts with a backslash and
+// runs to the end of the input, is shorter than its escape sequence.
+func escapeIncomplete(esc string) bool {
+	if len(esc) < 2 {
+		return true
+	}
+	return esc[1] == 'u' && len(esc) < 6
+}
+
+// highSurrogate reports whether esc, which starts with a backslash and runs
+// to the end of the input, is a \uXXXX escape of a UTF-16 high surrogate.
+func highSurrogate(esc string) bool {
+	if len(esc) != 6 || esc[1] != 'u' {
+		return false
+	}
+	n, err := strconv.ParseUint(esc[2:], 16, 16)
+	return err == nil && n >= 0xD800 && n < 0xDC00
+}
+
+// escaped reports whether the backslash at s[j] is itself escaped by an odd
+// number of preceding backslashes.
+func escaped(s string, j int) bool {
+	n := 0
+	for j--; j >= 0 && s[j] == '\\'; j-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// number parses a number. A number at the end of the input may still grow
+// and is not usable.
+func (p *partialParser) number() (any, bool, error) {
+	start := p.i
+	for !p.eof() && strings.IndexByte("+-.eE0123456789", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	if p.eof() {
+		return nil, false, nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(p.s[start:p.i]), &v); err != nil {
+		return nil, false, fmt.Errorf("partial json: number at offset %d: %w", start, err)
+	}
+	return v, true, nil
+}
+
+// literal parses true, false or null. A truncated literal is not usable.
+func (p *partialParser) literal() (any, bool, error) {
+	for _, lit := range []struct {
+		text  string
+		value any
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		rest := p.s[p.i:]
+		if strings.HasPrefix(rest, lit.text) {
+			p.i += len(lit.text)
+			return lit.value, true, nil
+		}
+		if len(rest) < len(lit.text) && strings.HasPrefix(lit.text, rest) {
+			p.i = len(p.s)
+			return nil, false, nil
+		}
+	}
+	return nil, false, p.unexpected()
+}
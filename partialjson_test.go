@@ -0,0 +1,96 @@
+package ccprotocol_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+func TestParsePartialJSON(t *testing.T) {
+	tests := []struct {
+		partial string
+		want    map[string]any
+	}{
+		{``, map[string]any{}},
+		{`{`, map[string]any{}},
+		{`{"comm`, map[string]any{}},
+		{`{"command"`, map[string]any{}},
+		{`{"command": `, map[string]any{}},
+		{`{"command": "ls -`, map[string]any{"command": "ls -"}},
+		{`{"command": "ls -la", "desc`, map[string]any{"command": "ls -la"}},
+		{`{"file_path":"/tmp/a\`, map[string]any{"file_path": "/tmp/a"}},
+		{`{"file_path":"/tmp/a\"b\\`, map[string]any{"file_path": `/tmp/a"b\`}},
+		{`{"text":"caf\u00`, map[string]any{"text": "caf"}},
+		{`{"text":"hi \ud83d`, map[string]any{"text": "hi "}},
+		{`{"text":"hi \ud83d\ude`, map[string]any{"text": "hi "}},
+		{`{"text":"hi \ud83d\ude00`, map[string]any{"text": "hi 😀"}},
+		{`{"text":"\\ud83d`, map[string]any{"text": `\ud83d`}},
+		{`{"text":"café`, map[string]any{"text": "café"}},
+		{"{\"text\":\"caf\xc3", map[string]any{"text": "caf"}},
+		{`{"limit": 10`, map[string]any{}},
+		{`{"limit": 10,`, map[string]any{"limit": 10.0}},
+		{`{"replace_all": tr`, map[string]any{}},
+		{`{"replace_all": true}`, map[string]any{"replace_all": true}},
+		{`{"old": null, "new": fals`, map[string]any{"old": nil}},
+		{`{"todos": [{"content": "a", "status": "pen`, map[string]any{"todos": []any{map[string]any{"content": "a", "status": "pen"}}}},
+		{`{"todos": [{"content": "a"}, `, map[string]any{"todos": []any{map[string]any{"content": "a"}}}},
+		{`{"nums": [1, 2`, map[string]any{"nums": []any{1.0}}},
+		{`{"a": {}, "b": []}`, map[string]any{"a": map[string]any{}, "b": []any{}}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePartialJSON(tt.partial)
+		if err != nil {
+			t.Errorf("ParsePartialJSON(%q): %v", tt.partial, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePartialJSON(%q) = %#v, want %#v", tt.partial, got, tt.want)
+		}
+	}
+}
+
+// Every prefix of a complete object parses into a prefix of each string, and
+// the complete object parses like json.Unmarshal.
+func TestParsePartialJSON_Prefixes(t *testing.T) {
+	const full = `{"command": "echo \"hi\" é ☃ \ud83d\ude00", "timeout": -1.5e3, "run_in_background": false, "edits": [{"old_string": "a\nb", "new_string": null}]}`
+	var want map[string]any
+	if err := json.Unmarshal([]byte(full), &want); err != nil {
+		t.Fatal(err)
+	}
+	for i := range len(full) {
+		got, err := ParsePartialJSON(full[:i])
+		if err != nil {
+			t.Fatalf("ParsePartialJSON(%q): %v", full[:i], err)
+		}
+		if c, ok := got["command"].(string); ok && !strings.HasPrefix(want["command"].(string), c) {
+			t.Errorf("ParsePartialJSON(%q) command = %q, want a prefix of %q", full[:i], c, want["command"])
+		}
+	}
+	got, err := ParsePartialJSON(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePartialJSON(full) = %#v, want %#v", got, want)
+	}
+}
+
+func TestParsePartialJSON_Invalid(t *testing.T) {
+	for _, partial := range []string{
+		`[`,
+		`"command"`,
+		`{"a" 1`,
+		`{"a": 1 "b"`,
+		`{"a": x`,
+		`{"a": 1}}`,
+		`{1: 2}`,
+		`{"a": [1 2]}`,
+	} {
+		if got, err := ParsePartialJSON(partial); err == nil {
+			t.Errorf("ParsePartialJSON(%q) = %#v, expected error", partial, got)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package ccprotocol
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PermissionAction is the outcome of a PermissionRule.
+type PermissionAction string
+
+const (
+	ActionAllow PermissionAction = "allow" // Run the tool
+	ActionDeny  PermissionAction = "deny"  // Refuse the tool call
+	ActionAsk   PermissionAction = "ask"   // Defer to PermissionPolicy.Ask
+)
+
+// PermissionRule matches can_use_tool requests. Every non-zero condition must
+// match for the rule to apply.
+type PermissionRule struct {
+	Name    string         // Identifies the rule in the audit trail
+	Tool    string         // Tool name glob, e.g. "Bash" or "mcp__github__*"
+	Path    string         // Glob on the request's path; "*" stops at "/", "**" does not
+	Command *regexp.Regexp // Matched against the Bash command
+	Mode    PermissionMode // Session permission mode
+
+	Action  PermissionAction                          // Outcome when the rule matches
+	Message string                                    // Denial message (deny)
+	Rewrite func(input map[string]any) map[string]any // Returns the input to run with (allow); must not modify input
+
+	// Remember applies the request's PermissionSuggestions when allowing, so
+	// the CLI stops asking for matching calls ("always allow"). RememberIn
+	// overrides the suggested destination, e.g. DestinationSession to avoid
+	// writing settings files.
+	Remember   bool
+	RememberIn PermissionUpdateDestination
+}
+
+// match reports whether r applies to req in the given permission mode. glob
+// returns the compiled Tool and Path globs.
+func (r PermissionRule) match(req CanUseToolRequest, mode PermissionMode, glob func(pattern string) *regexp.Regexp) bool {
+	if r.Tool != "" && !glob(r.Tool).MatchString(req.ToolName) {
+		return false
+	}
+	if r.Path != "" {
+		p := requestPath(req)
+		if p == "" || !glob(r.Path).MatchString(p) {
+			return false
+		}
+	}
+	if r.Command != nil {
+		cmd, ok := req.Input["command"].(string)
+		if !ok || req.ToolName != ToolBash || !r.Command.MatchString(cmd) {
+			return false
+		}
+	}
+	if r.Mode != "" && r.Mode != mode {
+		return false
+	}
+	return true
+}
+
+// PermissionDecision records how a PermissionPolicy answered one request.
+type PermissionDecision struct {
+	Time         time.Time
+	ToolName     string
+	ToolUseID    string
+	Input        map[string]any
+	Mode         PermissionMode     // Permission mode at the time of the request
+	Rule         string             // Name of the matching rule; empty when Default applied
+	Action       PermissionAction   // Action of the rule (or Default)
+	Behavior     string             // Final answer: "allow" or "deny"
+	Message      string             // Denial message
+	UpdatedInput map[string]any     // Input the tool runs with (allow)
+	Updates      []PermissionUpdate // Permission updates sent with the answer (allow)
+	Err          error              // Error returned by Ask, or an unknown Action
+}
+
+// PermissionPolicy answers can_use_tool requests by evaluating Rules in
+// order; the first matching rule decides. Every decision is kept in an
+// audit trail. Register it on a session with
+//
+//	controller.Handle(ccprotocol.ControlCanUseTool, policy.Handler())
+//
+// or pass it as client.Options.PermissionPolicy.
+type PermissionPolicy struct {
+	Rules []PermissionRule
+
+	// Default is the action when no rule matches (default ActionAsk).
+	Default PermissionAction
+
+	// Ask decides requests whose action is ActionAsk, e.g. by prompting a
+	// user. When nil, such requests are denied.
+	Ask func(ctx context.Context, req CanUseToolRequest) (PermissionPayload, error)
+
+	mu        sync.Mutex
+	mode      PermissionMode
+	decisions []PermissionDecision
+	globs     map[string]*regexp.Regexp // compiled rule globs by pattern
+}
+
+// SetMode sets the session permission mode matched by PermissionRule.Mode
+// in Evaluate and Handler. A policy shared by several sessions should be
+// registered with ModeHandler instead.
+func (p *PermissionPolicy) SetMode(mode PermissionMode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mode = mode
+}
+
+// Evaluate decides req in the mode set by SetMode and records the decision.
+func (p *PermissionPolicy) Evaluate(ctx context.Context, req CanUseToolRequest) (PermissionPayload, error) {
+	p.mu.Lock()
+	mode := p.mode
+	p.mu.Unlock()
+	return p.EvaluateMode(ctx, req, mode)
+}
+
+// EvaluateMode decides req in the given session permission mode and records
+// the decision.
+func (p *PermissionPolicy) EvaluateMode(ctx context.Context, req CanUseToolRequest, mode PermissionMode) (PermissionPayload, error) {
+	d := PermissionDecision{
+		Time:      time.Now(),
+		ToolName:  req.ToolName,
+		ToolUseID: req.ToolUseID,
+		Input:     req.Input,
+		Mode:      mode,
+		Action:    p.Default,
+	}
+	if d.Action == "" {
+		d.Action = ActionAsk
+	}
+	var rule *PermissionRule
+	for i := range p.Rules {
+		if p.Rules[i].match(req, mode, p.glob) {
+			rule = &p.Rules[i]
+			d.Rule = rule.Name
+			d.Action = rule.Action
+			break
+		}
+	}
+
+	var payload PermissionPayload
+	switch d.Action {
+	case ActionAllow:
+		input := req.Input
+		if rule != nil && rule.Rewrite != nil {
+			input = rule.Rewrite(input)
+		}
+		payload = PermissionPayload{Behavior: "allow", UpdatedInput: input}
+		if rule != nil && rule.Remember {
+			payload.UpdatedPermissions = req.Suggestions(rule.RememberIn)
+		}
+	case ActionDeny:
+		msg := "Denied by permission policy"
+		if rule != nil && rule.Message != "" {
+			msg = rule.Message
+		}
+		payload = PermissionPayload{Behavior: "deny", Message: msg}
+	case ActionAsk:
+		if p.Ask == nil {
+			payload = PermissionPayload{Behavior: "deny", Message: "No permission rule allows " + req.ToolName}
+			break
+		}
+		var err error
+		payload, err = p.Ask(ctx, req)
+		if err != nil {
+			d.Err = err
+			p.record(d)
+			return PermissionPayload{}, fmt.Errorf("ask permission for %s: %w", req.ToolName, err)
+		}
+	default:
+		d.Err = fmt.Errorf("unknown action %q", d.Action)
+		p.record(d)
+		return PermissionPayload{}, fmt.Errorf("evaluate permission for %s: %w", req.ToolName, d.Err)
+	}
+
+	d.Behavior = payload.Behavior
+	d.Message = payload.Message
+	d.UpdatedInput, _ = payload.UpdatedInput.(map[string]any)
+	d.Updates = payload.UpdatedPermissions
+	p.record(d)
+	return payload, nil
+}
+
+func (p *PermissionPolicy) record(d PermissionDecision) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decisions = append(p.decisions, d)
+}
+
+// Decisions returns the audit trail of all decisions so far, oldest first.
+func (p *PermissionPolicy) Decisions() []PermissionDecision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PermissionDecision(nil), p.decisions...)
+}
+
+// Handler returns a ControlHandler answering can_use_tool requests with
+// Evaluate.
+func (p *PermissionPolicy) Handler() ControlHandler {
+	return func(ctx context.Context, req IsControlRequest) (any, error) {
+		r, ok := req.(CanUseToolRequest)
+		if !ok {
+			return nil, fmt.Errorf("permission policy: unexpected %s request", req.controlSubtype())
+		}
+		return p.Evaluate(ctx, r)
+	}
+}
+
+// ModeHandler returns a ControlHandler answering can_use_tool requests with
+// EvaluateMode in the mode that mode reports for the session. Unlike
+// Handler it does not depend on SetMode, so one policy can serve several
+// sessions.
+func (p *PermissionPolicy) ModeHandler(mode func() PermissionMode) ControlHandler {
+	return func(ctx context.Context, req IsControlRequest) (any, error) {
+		r, ok := req.(CanUseToolRequest)
+		if !ok {
+			return nil, fmt.Errorf("permission policy: unexpected %s request", req.controlSubtype())
+		}
+		return p.EvaluateMode(ctx, r, mode())
+	}
+}
+
+// Suggestions returns the request's PermissionSuggestions for use as
+// PermissionPayload.UpdatedPermissions. A non-empty dest replaces the
+// suggested destination of every update.
+func (r CanUseToolRequest) Suggestions(dest PermissionUpdateDestination) []PermissionUpdate {
+	if r.PermissionSuggestions == nil {
+		return nil
+	}
+	updates := append([]PermissionUpdate(nil), r.PermissionSuggestions...)
+	if dest != "" {
+		for i := range updates {
+			updates[i].Destination = dest
+		}
+	}
+	return updates
+}
+
+// requestPath returns the path a can_use_tool request is about: BlockedPath,
+// or else the path input of file tools.
+func requestPath(req CanUseToolRequest) string {
+	if req.BlockedPath != "" {
+		return req.BlockedPath
+	}
+	for _, k := range []string{"file_path", "notebook_path", "path"} {
+		if s, ok := req.Input[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// glob returns the compiled form of a rule's Tool or Path glob, compiling it
+// on first use.
+func (p *PermissionPolicy) glob(pattern string) *regexp.Regexp {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if re, ok := p.globs[pattern]; ok {
+		return re
+	}
+	re := compileGlob(pattern)
+	if p.globs == nil {
+		p.globs = make(map[string]*regexp.Regexp)
+	}
+	p.globs[pattern] = re
+	return re
+}
+
+// compileGlob compiles a glob pattern into an anchored regexp. "*" and "?" do
+// not match "/"; "**" matches any sequence including "/".
+func compileGlob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	rs := []rune(pattern)
+	for i := 0; i < len(rs); i++ {
+		switch c := rs[i]; c {
+		case '*':
+			if i+1 < len(rs) && rs[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
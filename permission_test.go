@@ -0,0 +1,254 @@
+package ccprotocol_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+func canUseTool(tool string, input map[string]any) CanUseToolRequest {
+	return CanUseToolRequest{
+		ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool},
+		ToolName:           tool,
+		Input:              input,
+		ToolUseID:          "toolu_001",
+	}
+}
+
+func TestPermissionPolicy_Evaluate(t *testing.T) {
+	p := &PermissionPolicy{
+		Rules: []PermissionRule{
+			{Name: "no-rm", Tool: ToolBash, Command: regexp.MustCompile(`\brm\b`), Action: ActionDeny, Message: "rm is not allowed"},
+			{Name: "bash", Tool: ToolBash, Action: ActionAllow},
+			{Name: "tmp", Path: "/tmp/**", Action: ActionAllow},
+			{Name: "mcp", Tool: "mcp__github__*", Action: ActionAllow},
+		},
+		Default: ActionDeny,
+	}
+
+	tests := []struct {
+		req      CanUseToolRequest
+		rule     string
+		behavior string
+		message  string
+	}{
+		{canUseTool(ToolBash, map[string]any{"command": "rm -rf /"}), "no-rm", "deny", "rm is not allowed"},
+		{canUseTool(ToolBash, map[string]any{"command": "ls -la"}), "bash", "allow", ""},
+		{canUseTool(ToolWrite, map[string]any{"file_path": "/tmp/a/b.txt"}), "tmp", "allow", ""},
+		{canUseTool(ToolWrite, map[string]any{"file_path": "/home/u/a.txt"}), "", "deny", "Denied by permission policy"},
+		{canUseTool("mcp__github__create_issue", map[string]any{}), "mcp", "allow", ""},
+		{canUseTool("mcp__slack__post", map[string]any{}), "", "deny", "Denied by permission policy"},
+	}
+	for _, tt := range tests {
+		got, err := p.Evaluate(context.Background(), tt.req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Behavior != tt.behavior || got.Message != tt.message {
+			t.Errorf("Evaluate(%s %v) = %+v, want %s %q", tt.req.ToolName, tt.req.Input, got, tt.behavior, tt.message)
+		}
+	}
+
+	decisions := p.Decisions()
+	if len(decisions) != len(tests) {
+		t.Fatalf("len(Decisions()) = %d, want %d", len(decisions), len(tests))
+	}
+	for i, tt := range tests {
+		d := decisions[i]
+		if d.Rule != tt.rule || d.Behavior != tt.behavior || d.ToolName != tt.req.ToolName || d.ToolUseID != "toolu_001" {
+			t.Errorf("Decisions()[%d] = %+v, want rule %q behavior %s", i, d, tt.rule, tt.behavior)
+		}
+	}
+}
+
+func TestPermissionPolicy_Mode(t *testing.T) {
+	p := &PermissionPolicy{
+		Rules:   []PermissionRule{{Name: "plan", Mode: PermissionPlan, Action: ActionDeny, Message: "read-only"}},
+		Default: ActionAllow,
+	}
+	req := canUseTool(ToolWrite, map[string]any{"file_path": "/tmp/a.txt"})
+
+	if got, _ := p.Evaluate(context.Background(), req); got.Behavior != "allow" {
+		t.Errorf("default mode: Behavior = %q, want allow", got.Behavior)
+	}
+	p.SetMode(PermissionPlan)
+	if got, _ := p.Evaluate(context.Background(), req); got.Behavior != "deny" || got.Message != "read-only" {
+		t.Errorf("plan mode: got %+v, want deny read-only", got)
+	}
+	if d := p.Decisions()[1]; d.Mode != PermissionPlan {
+		t.Errorf("Decisions()[1].Mode = %q, want %q", d.Mode, PermissionPlan)
+	}
+}
+
+func TestPermissionPolicy_EvaluateMode(t *testing.T) {
+	p := &PermissionPolicy{
+		Rules:   []PermissionRule{{Name: "plan", Mode: PermissionPlan, Action: ActionDeny}},
+		Default: ActionAllow,
+	}
+	req := canUseTool(ToolWrite, map[string]any{"file_path": "/tmp/a.txt"})
+
+	// The mode argument wins over SetMode.
+	p.SetMode(PermissionPlan)
+	if got, _ := p.EvaluateMode(context.Background(), req, PermissionAcceptEdits); got.Behavior != "allow" {
+		t.Errorf("acceptEdits: Behavior = %q, want allow", got.Behavior)
+	}
+	if d := p.Decisions()[0]; d.Mode != PermissionAcceptEdits {
+		t.Errorf("Decisions()[0].Mode = %q, want %q", d.Mode, PermissionAcceptEdits)
+	}
+}
+
+func TestPermissionPolicy_UnknownAction(t *testing.T) {
+	p := &PermissionPolicy{Default: "maybe"}
+
+	if _, err := p.Evaluate(context.Background(), canUseTool(ToolRead, map[string]any{})); err == nil {
+		t.Fatal("expected error for unknown action, got nil")
+	}
+	d := p.Decisions()
+	if len(d) != 1 || d[0].Action != "maybe" || d[0].Err == nil {
+		t.Errorf("Decisions() = %+v, want one record with Err", d)
+	}
+}
+
+func TestPermissionPolicy_BlockedPath(t *testing.T) {
+	p := &PermissionPolicy{Rules: []PermissionRule{{Name: "tmp", Path: "/tmp/*", Action: ActionAllow}}}
+	req := canUseTool(ToolBash, map[string]any{"command": "rm -f /tmp/x"})
+	req.BlockedPath = "/tmp/x"
+
+	if got, _ := p.Evaluate(context.Background(), req); got.Behavior != "allow" {
+		t.Errorf("Behavior = %q, want allow", got.Behavior)
+	}
+	// "*" does not cross directories.
+	req.BlockedPath = "/tmp/a/x"
+	if got, _ := p.Evaluate(context.Background(), req); got.Behavior != "deny" {
+		t.Errorf("nested path: Behavior = %q, want deny", got.Behavior)
+	}
+}
+
+func TestPermissionPolicy_Rewrite(t *testing.T) {
+	p := &PermissionPolicy{Rules: []PermissionRule{{
+		Name:   "quiet",
+		Tool:   ToolBash,
+		Action: ActionAllow,
+		Rewrite: func(in map[string]any) map[string]any {
+			return map[string]any{"command": in["command"].(string) + " >/dev/null"}
+		},
+	}}}
+	in := map[string]any{"command": "make"}
+
+	got, err := p.Evaluate(context.Background(), canUseTool(ToolBash, in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{"command": "make >/dev/null"}
+	if !reflect.DeepEqual(got.UpdatedInput, want) {
+		t.Errorf("UpdatedInput = %v, want %v", got.UpdatedInput, want)
+	}
+	if d := p.Decisions()[0]; !reflect.DeepEqual(d.Input, in) || !reflect.DeepEqual(d.UpdatedInput, want) {
+		t.Errorf("decision Input = %v, UpdatedInput = %v", d.Input, d.UpdatedInput)
+	}
+}
+
+func TestPermissionPolicy_Remember(t *testing.T) {
+	p := &PermissionPolicy{Rules: []PermissionRule{{Name: "tmp", Path: "/tmp/**", Action: ActionAllow, Remember: true, RememberIn: DestinationSession}}}
+	req := canUseTool(ToolBash, map[string]any{"command": "rm -f /tmp/x"})
+	req.BlockedPath = "/tmp/x"
+	req.PermissionSuggestions = []PermissionUpdate{
+		{Type: PermissionUpdateAddRules, Rules: []PermissionRuleValue{{ToolName: ToolBash, RuleContent: "rm -f /tmp/x"}}, Behavior: "allow", Destination: DestinationLocalSettings},
+		{Type: PermissionUpdateAddDirectories, Directories: []string{"/tmp"}, Destination: DestinationSession},
+	}
+
+	got, err := p.Evaluate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PermissionUpdate{
+		{Type: PermissionUpdateAddRules, Rules: []PermissionRuleValue{{ToolName: ToolBash, RuleContent: "rm -f /tmp/x"}}, Behavior: "allow", Destination: DestinationSession},
+		{Type: PermissionUpdateAddDirectories, Directories: []string{"/tmp"}, Destination: DestinationSession},
+	}
+	if !reflect.DeepEqual(got.UpdatedPermissions, want) {
+		t.Errorf("UpdatedPermissions = %+v, want %+v", got.UpdatedPermissions, want)
+	}
+	// The request's suggestions are not modified.
+	if req.PermissionSuggestions[0].Destination != DestinationLocalSettings {
+		t.Errorf("suggestion destination changed to %q", req.PermissionSuggestions[0].Destination)
+	}
+	if d := p.Decisions()[0]; !reflect.DeepEqual(d.Updates, want) {
+		t.Errorf("decision Updates = %+v, want %+v", d.Updates, want)
+	}
+}
+
+func TestPermissionPolicy_Ask(t *testing.T) {
+	var asked []string
+	p := &PermissionPolicy{
+		Rules: []PermissionRule{{Name: "ask-web", Tool: "Web*", Action: ActionAsk}},
+		Ask: func(_ context.Context, req CanUseToolRequest) (PermissionPayload, error) {
+			asked = append(asked, req.ToolName)
+			if req.ToolName == ToolWebSearch {
+				return PermissionPayload{}, errors.New("prompt closed")
+			}
+			return PermissionPayload{Behavior: "allow", UpdatedInput: req.Input}, nil
+		},
+	}
+
+	if got, err := p.Evaluate(context.Background(), canUseTool(ToolWebFetch, map[string]any{})); err != nil || got.Behavior != "allow" {
+		t.Errorf("WebFetch: got %+v, %v; want allow", got, err)
+	}
+	if _, err := p.Evaluate(context.Background(), canUseTool(ToolWebSearch, map[string]any{})); err == nil {
+		t.Error("WebSearch: expected error from Ask, got nil")
+	}
+	// Default is ActionAsk, so unmatched tools are asked too.
+	if got, _ := p.Evaluate(context.Background(), canUseTool(ToolRead, map[string]any{})); got.Behavior != "allow" {
+		t.Errorf("Read: Behavior = %q, want allow", got.Behavior)
+	}
+	if !reflect.DeepEqual(asked, []string{ToolWebFetch, ToolWebSearch, ToolRead}) {
+		t.Errorf("asked = %v", asked)
+	}
+	if d := p.Decisions()[1]; d.Err == nil || d.Action != ActionAsk {
+		t.Errorf("Decisions()[1] = %+v, want Ask with error", d)
+	}
+
+	// Without Ask, ask outcomes are denied.
+	p.Ask = nil
+	if got, _ := p.Evaluate(context.Background(), canUseTool(ToolRead, map[string]any{})); got.Behavior != "deny" {
+		t.Errorf("no Ask: Behavior = %q, want deny", got.Behavior)
+	}
+}
+
+func TestPermissionPolicy_Handler(t *testing.T) {
+	c, tr := newController(t)
+	p := &PermissionPolicy{Default: ActionAllow}
+	c.Handle(ControlCanUseTool, p.Handler())
+
+	c.Dispatch(&ControlRequestMessage{
+		MessageBase: MessageBase{Type: TypeControlRequest},
+		RequestID:   "r1",
+		Request:     canUseTool(ToolRead, map[string]any{"file_path": "/tmp/a.txt"}),
+	})
+	m := tr.next(t).(*ControlResponseMessage)
+	if m.Response.RequestID != "r1" || m.Response.Response.(PermissionPayload).Behavior != "allow" {
+		t.Errorf("response = %+v", m.Response)
+	}
+}
+
+func TestPermissionPolicy_ModeHandler(t *testing.T) {
+	c, tr := newController(t)
+	p := &PermissionPolicy{
+		Rules:   []PermissionRule{{Name: "plan", Mode: PermissionPlan, Action: ActionDeny}},
+		Default: ActionAllow,
+	}
+	c.Handle(ControlCanUseTool, p.ModeHandler(func() PermissionMode { return PermissionPlan }))
+
+	c.Dispatch(&ControlRequestMessage{
+		MessageBase: MessageBase{Type: TypeControlRequest},
+		RequestID:   "r1",
+		Request:     canUseTool(ToolRead, map[string]any{"file_path": "/tmp/a.txt"}),
+	})
+	m := tr.next(t).(*ControlResponseMessage)
+	if m.Response.Response.(PermissionPayload).Behavior != "deny" {
+		t.Errorf("response = %+v, want deny", m.Response)
+	}
+}
@@ -1,6 +1,9 @@
 //go:generate go run ./cmd/gendoc
+//go:generate go run ./cmd/genschema
 package ccprotocol
 
+import "encoding/json"
+
 // ---------------------------------------------------------------------------
 // Enum
 // ---------------------------------------------------------------------------
@@ -43,6 +46,26 @@ const (
 	BlockToolResult ContentBlockType = "tool_result"
 )
 
+type StreamEventType string
+
+const (
+	EventMessageStart      StreamEventType = "message_start"
+	EventContentBlockStart StreamEventType = "content_block_start"
+	EventContentBlockDelta StreamEventType = "content_block_delta"
+	EventContentBlockStop  StreamEventType = "content_block_stop"
+	EventMessageDelta      StreamEventType = "message_delta"
+	EventMessageStop       StreamEventType = "message_stop"
+)
+
+type DeltaType string
+
+const (
+	DeltaText      DeltaType = "text_delta"
+	DeltaInputJSON DeltaType = "input_json_delta"
+	DeltaThinking  DeltaType = "thinking_delta"
+	DeltaSignature DeltaType = "signature_delta"
+)
+
 type ControlSubtype string
 
 const (
@@ -52,6 +75,7 @@ const (
 	ControlInterrupt            ControlSubtype = "interrupt"
 	ControlSetMaxThinkingTokens ControlSubtype = "set_max_thinking_tokens"
 	ControlInitialize           ControlSubtype = "initialize"
+	ControlHookCallback         ControlSubtype = "hook_callback"
 )
 
 type PermissionMode string
@@ -65,6 +89,27 @@ const (
 	PermissionDelegate          PermissionMode = "delegate"
 )
 
+type PermissionUpdateType string
+
+const (
+	PermissionUpdateAddRules          PermissionUpdateType = "addRules"
+	PermissionUpdateReplaceRules      PermissionUpdateType = "replaceRules"
+	PermissionUpdateRemoveRules       PermissionUpdateType = "removeRules"
+	PermissionUpdateSetMode           PermissionUpdateType = "setMode"
+	PermissionUpdateAddDirectories    PermissionUpdateType = "addDirectories"
+	PermissionUpdateRemoveDirectories PermissionUpdateType = "removeDirectories"
+)
+
+type PermissionUpdateDestination string
+
+const (
+	DestinationUserSettings    PermissionUpdateDestination = "userSettings"
+	DestinationProjectSettings PermissionUpdateDestination = "projectSettings"
+	DestinationLocalSettings   PermissionUpdateDestination = "localSettings"
+	DestinationSession         PermissionUpdateDestination = "session"
+	DestinationCLIArg          PermissionUpdateDestination = "cliArg"
+)
+
 type FastModeState string
 
 const (
@@ -93,6 +138,17 @@ type IsContentBlock interface {
 	isContentBlock()
 }
 
+// IsStreamEvent is the interface that constrains stream event types.
+type IsStreamEvent interface {
+	isStreamEvent()
+}
+
+// IsControlRequest is the interface that constrains control request payload types.
+type IsControlRequest interface {
+	isControlRequest()
+	controlSubtype() ControlSubtype
+}
+
 // ---------------------------------------------------------------------------
 // Base types
 // ---------------------------------------------------------------------------
@@ -101,17 +157,48 @@ type IsContentBlock interface {
 type MessageBase struct {
 	Type    MessageType    `json:"type"`
 	Subtype MessageSubtype `json:"subtype,omitempty"`
+	Extra   Extra          `json:"-"` // Fields not modeled by the message type
 }
 
 func (MessageBase) isMessage() {}
 
+// Nullable holds a JSON value that may be null. The zero value is null.
+type Nullable[T any] struct {
+	Value T    // Value when Valid is true
+	Valid bool // false when the JSON value is null
+}
+
+// NewNullable returns a non-null Nullable holding v.
+func NewNullable[T any](v T) Nullable[T] {
+	return Nullable[T]{Value: v, Valid: true}
+}
+
 // ContentBlockBase holds fields common to all content blocks.
 type ContentBlockBase struct {
-	Type ContentBlockType `json:"type"`
+	Type  ContentBlockType `json:"type"`
+	Extra Extra            `json:"-"` // Fields not modeled by the block type
 }
 
 func (ContentBlockBase) isContentBlock() {}
 
+// StreamEventBase holds fields common to all stream events.
+type StreamEventBase struct {
+	Type  StreamEventType `json:"type"`
+	Extra Extra           `json:"-"` // Fields not modeled by the event type
+}
+
+func (StreamEventBase) isStreamEvent() {}
+
+// ControlRequestBase holds fields common to all control request payloads.
+type ControlRequestBase struct {
+	Subtype ControlSubtype `json:"subtype"`
+	Extra   Extra          `json:"-"` // Fields not modeled by the request type
+}
+
+func (ControlRequestBase) isControlRequest() {}
+
+func (b ControlRequestBase) controlSubtype() ControlSubtype { return b.Subtype }
+
 // ---------------------------------------------------------------------------
 // Message types
 // ---------------------------------------------------------------------------
@@ -152,10 +239,10 @@ type SystemInitMessage struct {
 // ```
 type SystemStatusMessage struct {
 	MessageBase
-	Status         string         `json:"status,omitempty"` // Status (null or string)
-	PermissionMode PermissionMode `json:"permissionMode"`   // Permission mode
-	UUID           string         `json:"uuid"`             // Message UUID
-	SessionID      string         `json:"session_id"`       // Session ID
+	Status         Nullable[string] `json:"status"`         // Status (null or string)
+	PermissionMode PermissionMode   `json:"permissionMode"` // Permission mode
+	UUID           string           `json:"uuid"`           // Message UUID
+	SessionID      string           `json:"session_id"`     // Session ID
 }
 
 // # assistant
@@ -167,7 +254,7 @@ type SystemStatusMessage struct {
 // A text response content block. The text field contains the response text.
 //
 // ```json
-// {"type":"assistant","message":{"content":[{"type":"text","text":"Hello!"}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
+// {"type":"assistant","message":{"content":[{"type":"text","text":"Hello!"}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
 // ```
 //
 // #### assistant(tool_use)
@@ -175,7 +262,7 @@ type SystemStatusMessage struct {
 // A tool use content block. The name field contains the tool name and the input field contains the parameters.
 //
 // ```json
-// {"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_001","name":"Bash","input":{"command":"echo hello"}}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
+// {"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_001","name":"Bash","input":{"command":"echo hello"}}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
 // ```
 //
 // #### assistant(thinking)
@@ -183,14 +270,14 @@ type SystemStatusMessage struct {
 // An extended thinking content block. The thinking field contains the thinking content.
 //
 // ```json
-// {"type":"assistant","message":{"content":[{"type":"thinking","thinking":"Let me think...","signature":""}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
+// {"type":"assistant","message":{"content":[{"type":"thinking","thinking":"Let me think...","signature":""}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
 // ```
 type AssistantMessage struct {
 	MessageBase
-	Message         AssistantBody `json:"message"`
-	ParentToolUseID string        `json:"parent_tool_use_id,omitempty"` // Parent tool use ID (null or string)
-	SessionID       string        `json:"session_id"`                   // Session ID
-	UUID            string        `json:"uuid"`                         // Message UUID
+	Message         AssistantBody    `json:"message"`
+	ParentToolUseID Nullable[string] `json:"parent_tool_use_id"` // Parent tool use ID (null or string)
+	SessionID       string           `json:"session_id"`         // Session ID
+	UUID            string           `json:"uuid"`               // Message UUID
 }
 
 // # user
@@ -210,6 +297,15 @@ type AssistantMessage struct {
 // ```json
 // {"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_001","content":"command output"}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx","tool_use_result":{}}
 // ```
+//
+// #### user(content)
+//
+// A message the CLI emits with other blocks in the content array, such as the
+// text block "[Request interrupted by user]" after an interrupt.
+//
+// ```json
+// {"type":"user","message":{"role":"user","content":[{"type":"text","text":"[Request interrupted by user]"}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"xxx"}
+// ```
 type UserTextMessage struct {
 	MessageBase
 	Message UserTextBody `json:"message"`
@@ -220,35 +316,38 @@ type UserTextMessage struct {
 type UserToolResultMessage struct {
 	MessageBase
 	Message         UserToolResultBody `json:"message"`
-	ParentToolUseID string             `json:"parent_tool_use_id,omitempty"` // Parent tool use ID (null or string)
-	SessionID       string             `json:"session_id"`                   // Session ID
-	UUID            string             `json:"uuid"`                         // Message UUID
-	ToolUseResult   any                `json:"tool_use_result"`              // Tool execution result (map or string or null)
+	ParentToolUseID Nullable[string]   `json:"parent_tool_use_id"` // Parent tool use ID (null or string)
+	SessionID       string             `json:"session_id"`         // Session ID
+	UUID            string             `json:"uuid"`               // Message UUID
+	ToolUseResult   any                `json:"tool_use_result"`    // Tool execution result (map or string or null)
+}
+
+// UserContentMessage is a user message whose content array holds blocks other
+// than tool_result, e.g. the notice the CLI emits after an interrupt.
+// See the # user section of UserTextMessage for documentation.
+type UserContentMessage struct {
+	MessageBase
+	Message         UserContentBody  `json:"message"`
+	ParentToolUseID Nullable[string] `json:"parent_tool_use_id"` // Parent tool use ID (null or string)
+	SessionID       string           `json:"session_id"`         // Session ID
+	UUID            string           `json:"uuid"`               // Message UUID
 }
 
 // # result/success
 // A message indicating successful completion of a turn. Indicates that processing of one turn completed normally.
 // The result field contains the last text block content.
 // permission_denials is always present; when empty, it is an empty array [].
+// usage holds the token totals of the turn (snake_case keys); modelUsage breaks usage
+// and cost down per model name (camelCase keys).
 //
 // ```json
-// {"type":"result","subtype":"success","is_error":false,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"Hello!","stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx"}
+// {"type":"result","subtype":"success","is_error":false,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"Hello!","stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1,"server_tool_use":{"web_search_requests":0,"web_fetch_requests":0},"service_tier":"standard","cache_creation":{"ephemeral_1h_input_tokens":0,"ephemeral_5m_input_tokens":0}},"modelUsage":{"claude-sonnet-4-5-20250929":{"inputTokens":10,"outputTokens":1,"cacheReadInputTokens":0,"cacheCreationInputTokens":0,"webSearchRequests":0,"costUSD":0.00055,"contextWindow":200000,"maxOutputTokens":64000}},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx"}
 // ```
 type ResultSuccessMessage struct {
 	MessageBase
-	IsError           bool               `json:"is_error"`              // true on error
-	DurationMs        float64            `json:"duration_ms"`           // Total duration (ms)
-	DurationApiMs     float64            `json:"duration_api_ms"`       // API duration (ms)
-	NumTurns          float64            `json:"num_turns"`             // Number of turns
-	Result            string             `json:"result"`                // Last text block content
-	StopReason        string             `json:"stop_reason,omitempty"` // Stop reason (null or string)
-	SessionID         string             `json:"session_id"`            // Session ID
-	TotalCostUSD      float64            `json:"total_cost_usd"`        // Total cost (USD)
-	Usage             map[string]any     `json:"usage"`                 // Token usage
-	ModelUsage        map[string]any     `json:"modelUsage"`            // Per-model usage
-	PermissionDenials []PermissionDenial `json:"permission_denials"`    // Permission denials (always present)
-	FastModeState     FastModeState      `json:"fast_mode_state"`       // Fast mode state ("off", "on", "cooldown")
-	UUID              string             `json:"uuid"`                  // Message UUID
+	ResultCommon
+	Result     string           `json:"result"`      // Last text block content
+	StopReason Nullable[string] `json:"stop_reason"` // Stop reason (null or string)
 }
 
 // # result/error_during_execution
@@ -256,22 +355,46 @@ type ResultSuccessMessage struct {
 // In addition to the same common fields as result/success, the errors array contains error message strings.
 //
 // ```json
-// {"type":"result","subtype":"error_during_execution","is_error":false,"duration_ms":52,"duration_api_ms":18,"num_turns":1,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx","errors":["error message"]}
+// {"type":"result","subtype":"error_during_execution","is_error":false,"duration_ms":52,"duration_api_ms":18,"num_turns":1,"session_id":"abc","total_cost_usd":0,"usage":{"input_tokens":0,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":0,"server_tool_use":{"web_search_requests":0,"web_fetch_requests":0},"service_tier":"standard","cache_creation":{"ephemeral_1h_input_tokens":0,"ephemeral_5m_input_tokens":0}},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx","errors":["error message"]}
 // ```
 type ResultErrorMessage struct {
 	MessageBase
-	IsError           bool               `json:"is_error"`           // Error flag
-	DurationMs        float64            `json:"duration_ms"`        // Total duration (ms)
-	DurationApiMs     float64            `json:"duration_api_ms"`    // API duration (ms)
-	NumTurns          float64            `json:"num_turns"`          // Number of turns
-	SessionID         string             `json:"session_id"`         // Session ID
-	TotalCostUSD      float64            `json:"total_cost_usd"`     // Total cost (USD)
-	Usage             map[string]any     `json:"usage"`              // Token usage
-	ModelUsage        map[string]any     `json:"modelUsage"`         // Per-model usage
-	PermissionDenials []PermissionDenial `json:"permission_denials"` // Permission denials (always present)
-	FastModeState     FastModeState      `json:"fast_mode_state"`    // Fast mode state ("off", "on", "cooldown")
-	UUID              string             `json:"uuid"`               // Message UUID
-	Errors            []string           `json:"errors"`             // Error array
+	ResultCommon
+	Errors []string `json:"errors"` // Error array
+}
+
+// ResultOtherMessage is a result message whose subtype has no dedicated type
+// (e.g. a subtype added by a newer CLI). DecodeMessage returns it for any such
+// subtype; fields beyond the common ones are kept in Extra.
+type ResultOtherMessage struct {
+	MessageBase
+	ResultCommon
+	Errors []string `json:"errors,omitempty"` // Error array (if present)
+}
+
+// ResultCommon holds the fields shared by all result subtypes. The result
+// types embed it; the CLI emits subtype-specific fields such as result and
+// stop_reason right after num_turns.
+type ResultCommon struct {
+	IsError           bool                  `json:"is_error"`           // true on error
+	DurationMs        float64               `json:"duration_ms"`        // Total duration (ms)
+	DurationApiMs     float64               `json:"duration_api_ms"`    // API duration (ms)
+	NumTurns          float64               `json:"num_turns"`          // Number of turns
+	SessionID         string                `json:"session_id"`         // Session ID
+	TotalCostUSD      float64               `json:"total_cost_usd"`     // Total cost (USD)
+	Usage             Usage                 `json:"usage"`              // Token usage
+	ModelUsage        map[string]ModelUsage `json:"modelUsage"`         // Per-model usage, keyed by model name
+	PermissionDenials []PermissionDenial    `json:"permission_denials"` // Permission denials (always present)
+	FastModeState     FastModeState         `json:"fast_mode_state"`    // Fast mode state ("off", "on", "cooldown")
+	UUID              string                `json:"uuid"`               // Message UUID
+}
+
+// UnknownMessage holds a message whose type or subtype is not recognized.
+// DecodeMessage returns it only when the Lenient option is given; Raw holds
+// the original JSON and is emitted unchanged when re-encoded.
+type UnknownMessage struct {
+	MessageBase
+	Raw json.RawMessage `json:"-"` // Original message JSON
 }
 
 // ---------------------------------------------------------------------------
@@ -307,6 +430,14 @@ type ToolResultBlock struct {
 	IsError   bool   `json:"is_error,omitempty"` // true on error
 }
 
+// UnknownBlock holds a content block whose type is not recognized.
+// DecodeContentBlock returns it only when the Lenient option is given; Raw
+// holds the original JSON and is emitted unchanged when re-encoded.
+type UnknownBlock struct {
+	ContentBlockBase
+	Raw json.RawMessage `json:"-"` // Original block JSON
+}
+
 // ---------------------------------------------------------------------------
 // Other
 // ---------------------------------------------------------------------------
@@ -317,22 +448,32 @@ type AssistantBody struct {
 	ID           string            `json:"id"`    // Message ID
 	Model        string            `json:"model"` // Model name
 	Role         MessageRole       `json:"role"`
-	StopReason   string            `json:"stop_reason,omitempty"`   // Stop reason (null or string)
-	StopSequence string            `json:"stop_sequence,omitempty"` // Stop sequence (null or string)
-	BodyType     AssistantBodyType `json:"type"`                    // Always "message"
-	Usage        map[string]any    `json:"usage"`                   // Token usage
+	StopReason   Nullable[string]  `json:"stop_reason"`   // Stop reason (null or string)
+	StopSequence Nullable[string]  `json:"stop_sequence"` // Stop sequence (null or string)
+	BodyType     AssistantBodyType `json:"type"`          // Always "message"
+	Usage        Usage             `json:"usage"`         // Token usage
+	Extra        Extra             `json:"-"`             // Fields not modeled by the type
 }
 
 // UserTextBody is the body of a user text message.
 type UserTextBody struct {
 	Role    MessageRole `json:"role"`
 	Content string      `json:"content"`
+	Extra   Extra       `json:"-"` // Fields not modeled by the type
 }
 
 // UserToolResultBody is the body of a user tool result message.
 type UserToolResultBody struct {
 	Role    MessageRole       `json:"role"` // Always "user"
 	Content []ToolResultBlock `json:"content"`
+	Extra   Extra             `json:"-"` // Fields not modeled by the type
+}
+
+// UserContentBody is the body of a user content message.
+type UserContentBody struct {
+	Role    MessageRole      `json:"role"` // Always "user"
+	Content []IsContentBlock `json:"content"`
+	Extra   Extra            `json:"-"` // Fields not modeled by the type
 }
 
 // PermissionDenial holds information about a denied tool.
@@ -340,6 +481,64 @@ type PermissionDenial struct {
 	ToolName  string         `json:"tool_name"`   // Tool name
 	ToolUseID string         `json:"tool_use_id"` // Tool use ID
 	ToolInput map[string]any `json:"tool_input"`  // Tool input parameters
+	Extra     Extra          `json:"-"`           // Fields not modeled by the type
+}
+
+// Usage holds the token usage of an API response (assistant messages) or of
+// a whole turn (result messages). The nested objects and service tier are
+// only present in some responses.
+type Usage struct {
+	InputTokens              int            `json:"input_tokens"`
+	CacheCreationInputTokens int            `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int            `json:"cache_read_input_tokens"`
+	OutputTokens             int            `json:"output_tokens"`
+	ServerToolUse            *ServerToolUse `json:"server_tool_use,omitempty"` // Server-side tool requests
+	ServiceTier              string         `json:"service_tier,omitempty"`    // e.g. "standard"
+	CacheCreation            *CacheCreation `json:"cache_creation,omitempty"`  // Cache writes by TTL
+	Extra                    Extra          `json:"-"`                         // Fields not modeled by the type
+}
+
+// ServerToolUse counts server-side tool requests in Usage.
+type ServerToolUse struct {
+	WebSearchRequests int   `json:"web_search_requests"`
+	WebFetchRequests  int   `json:"web_fetch_requests"`
+	Extra             Extra `json:"-"` // Fields not modeled by the type
+}
+
+// CacheCreation breaks down Usage.CacheCreationInputTokens by cache TTL.
+type CacheCreation struct {
+	Ephemeral1hInputTokens int   `json:"ephemeral_1h_input_tokens"`
+	Ephemeral5mInputTokens int   `json:"ephemeral_5m_input_tokens"`
+	Extra                  Extra `json:"-"` // Fields not modeled by the type
+}
+
+// TotalInputTokens returns the input tokens including cache reads and writes.
+func (u Usage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// TotalTokens returns all input and output tokens.
+func (u Usage) TotalTokens() int {
+	return u.TotalInputTokens() + u.OutputTokens
+}
+
+// ModelUsage holds the usage and cost of one model over a turn, as reported
+// in the modelUsage field of result messages. Unlike Usage, keys are camelCase.
+type ModelUsage struct {
+	InputTokens              int     `json:"inputTokens"`
+	OutputTokens             int     `json:"outputTokens"`
+	CacheReadInputTokens     int     `json:"cacheReadInputTokens"`
+	CacheCreationInputTokens int     `json:"cacheCreationInputTokens"`
+	WebSearchRequests        int     `json:"webSearchRequests"`
+	CostUSD                  float64 `json:"costUSD"`         // Cost (USD)
+	ContextWindow            int     `json:"contextWindow"`   // Context window size (tokens)
+	MaxOutputTokens          int     `json:"maxOutputTokens"` // Output token limit
+	Extra                    Extra   `json:"-"`               // Fields not modeled by the type
+}
+
+// TotalTokens returns all input (including cache) and output tokens.
+func (u ModelUsage) TotalTokens() int {
+	return u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens + u.OutputTokens
 }
 
 // # result/error_max_turns
@@ -347,22 +546,13 @@ type PermissionDenial struct {
 // The subtype is "error_max_turns" and errors is an empty array.
 //
 // ```json
-// {"type":"result","subtype":"error_max_turns","is_error":false,"duration_ms":184,"duration_api_ms":30,"num_turns":2,"stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx","errors":[]}
+// {"type":"result","subtype":"error_max_turns","is_error":false,"duration_ms":184,"duration_api_ms":30,"num_turns":2,"stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1,"server_tool_use":{"web_search_requests":0,"web_fetch_requests":0},"service_tier":"standard","cache_creation":{"ephemeral_1h_input_tokens":0,"ephemeral_5m_input_tokens":0}},"modelUsage":{"claude-sonnet-4-5-20250929":{"inputTokens":10,"outputTokens":1,"cacheReadInputTokens":0,"cacheCreationInputTokens":0,"webSearchRequests":0,"costUSD":0.00055,"contextWindow":200000,"maxOutputTokens":64000}},"permission_denials":[],"fast_mode_state":"off","uuid":"xxx","errors":[]}
 // ```
 type ResultMaxTurnsMessage struct {
 	MessageBase
-	IsError           bool               `json:"is_error"`           // Error flag
-	DurationMs        float64            `json:"duration_ms"`        // Total duration (ms)
-	DurationApiMs     float64            `json:"duration_api_ms"`    // API duration (ms)
-	NumTurns          float64            `json:"num_turns"`          // Number of turns
-	SessionID         string             `json:"session_id"`         // Session ID
-	TotalCostUSD      float64            `json:"total_cost_usd"`     // Total cost (USD)
-	Usage             map[string]any     `json:"usage"`              // Token usage
-	ModelUsage        map[string]any     `json:"modelUsage"`         // Per-model usage
-	PermissionDenials []PermissionDenial `json:"permission_denials"` // Permission denials (always present)
-	FastModeState     FastModeState      `json:"fast_mode_state"`    // Fast mode state ("off", "on", "cooldown")
-	UUID              string             `json:"uuid"`               // Message UUID
-	Errors            []string           `json:"errors"`             // Error array (empty)
+	ResultCommon
+	StopReason Nullable[string] `json:"stop_reason"` // Stop reason (null or string)
+	Errors     []string         `json:"errors"`      // Error array (empty)
 }
 
 // # stream_event
@@ -370,17 +560,86 @@ type ResultMaxTurnsMessage struct {
 // Contains SSE stream events as they arrive from the API.
 // The event field contains the raw SSE event data (message_start,
 // content_block_start, content_block_delta, content_block_stop,
-// message_delta, message_stop).
+// message_delta, message_stop); DecodeEvent converts it to a typed event
+// and StreamAssembler rebuilds the assistant message from the events.
+// ParsePartialJSON reads a tool_use input from its input_json_delta
+// fragments before the block is complete.
 //
 // ```json
 // {"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}},"session_id":"abc","parent_tool_use_id":null,"uuid":"xxx"}
 // ```
 type StreamEventMessage struct {
 	MessageBase
-	Event           map[string]any `json:"event"`                        // SSE event data
-	SessionID       string         `json:"session_id"`                   // Session ID
-	ParentToolUseID string         `json:"parent_tool_use_id,omitempty"` // Parent tool use ID (null or string)
-	UUID            string         `json:"uuid"`                         // Message UUID
+	Event           map[string]any   `json:"event"`              // SSE event data
+	SessionID       string           `json:"session_id"`         // Session ID
+	ParentToolUseID Nullable[string] `json:"parent_tool_use_id"` // Parent tool use ID (null or string)
+	UUID            string           `json:"uuid"`               // Message UUID
+}
+
+// MessageStartEvent is the message_start stream event. Message holds the
+// message metadata with empty content.
+type MessageStartEvent struct {
+	StreamEventBase
+	Message AssistantBody `json:"message"`
+}
+
+// ContentBlockStartEvent is the content_block_start stream event. The block
+// is empty (text "", input {}) and is filled by the following deltas.
+type ContentBlockStartEvent struct {
+	StreamEventBase
+	Index        int            `json:"index"`
+	ContentBlock IsContentBlock `json:"content_block"`
+}
+
+// ContentBlockDeltaEvent is the content_block_delta stream event.
+type ContentBlockDeltaEvent struct {
+	StreamEventBase
+	Index int          `json:"index"`
+	Delta ContentDelta `json:"delta"`
+}
+
+// ContentDelta is an increment of a content block. The Type field
+// determines which field is populated.
+type ContentDelta struct {
+	Type        DeltaType `json:"type"`
+	Text        string    `json:"text,omitempty"`         // text_delta
+	PartialJSON string    `json:"partial_json,omitempty"` // input_json_delta: fragment of the tool input JSON
+	Thinking    string    `json:"thinking,omitempty"`     // thinking_delta
+	Signature   string    `json:"signature,omitempty"`    // signature_delta
+	Extra       Extra     `json:"-"`                      // Fields not modeled by the type
+}
+
+// ContentBlockStopEvent is the content_block_stop stream event.
+type ContentBlockStopEvent struct {
+	StreamEventBase
+	Index int `json:"index"`
+}
+
+// MessageDeltaEvent is the message_delta stream event, carrying the stop
+// reason and the final output token count.
+type MessageDeltaEvent struct {
+	StreamEventBase
+	Delta MessageDelta `json:"delta"`
+	Usage Usage        `json:"usage"`
+}
+
+// MessageDelta is the delta of a MessageDeltaEvent.
+type MessageDelta struct {
+	StopReason   Nullable[string] `json:"stop_reason"`
+	StopSequence Nullable[string] `json:"stop_sequence"`
+	Extra        Extra            `json:"-"` // Fields not modeled by the type
+}
+
+// MessageStopEvent is the message_stop stream event.
+type MessageStopEvent struct {
+	StreamEventBase
+}
+
+// UnknownStreamEvent holds a stream event whose type is not recognized.
+// Raw contains the original JSON and is emitted verbatim when encoding.
+type UnknownStreamEvent struct {
+	StreamEventBase
+	Raw json.RawMessage `json:"-"` // Original JSON
 }
 
 // # user (replay)
@@ -393,40 +652,189 @@ type StreamEventMessage struct {
 // ```
 type UserReplayMessage struct {
 	MessageBase
-	Message         UserTextBody `json:"message"`
-	SessionID       string       `json:"session_id"`                   // Session ID
-	ParentToolUseID string       `json:"parent_tool_use_id,omitempty"` // Parent tool use ID (null or string)
-	UUID            string       `json:"uuid"`                         // Message UUID
-	IsReplay        bool         `json:"isReplay"`                     // Always true for replayed messages
-}
-
-// ControlRequest is the request payload inside a ControlRequestMessage.
-// The Subtype field determines which optional fields are populated.
-type ControlRequest struct {
-	Subtype               ControlSubtype `json:"subtype"`
-	ToolName              string         `json:"tool_name,omitempty"`              // can_use_tool
-	Input                 map[string]any `json:"input,omitempty"`                  // can_use_tool
-	ToolUseID             string         `json:"tool_use_id,omitempty"`            // can_use_tool
-	PermissionSuggestions []string       `json:"permission_suggestions,omitempty"` // can_use_tool: suggested permission rules
-	DecisionReason        string         `json:"decision_reason,omitempty"`        // can_use_tool: why permission is required
-	BlockedPath           string         `json:"blocked_path,omitempty"`           // can_use_tool: path that triggered the permission check
-	Mode                  string         `json:"mode,omitempty"`                   // set_permission_mode
-	Model                 string         `json:"model,omitempty"`                  // set_model
+	Message         UserTextBody     `json:"message"`
+	SessionID       string           `json:"session_id"`         // Session ID
+	ParentToolUseID Nullable[string] `json:"parent_tool_use_id"` // Parent tool use ID (null or string)
+	UUID            string           `json:"uuid"`               // Message UUID
+	IsReplay        bool             `json:"isReplay"`           // Always true for replayed messages
+}
+
+// CanUseToolRequest is the can_use_tool control request, sent by the CLI on
+// stdout to ask whether a tool may run (--permission-prompt-tool stdio).
+// Answer it with a PermissionPayload response.
+type CanUseToolRequest struct {
+	ControlRequestBase
+	ToolName              string             `json:"tool_name"`
+	Input                 map[string]any     `json:"input"`
+	ToolUseID             string             `json:"tool_use_id,omitempty"`
+	PermissionSuggestions []PermissionUpdate `json:"permission_suggestions,omitempty"` // Updates an "always allow" answer would apply
+	DecisionReason        string             `json:"decision_reason,omitempty"`        // Why permission is required
+	BlockedPath           string             `json:"blocked_path,omitempty"`           // Path that triggered the permission check
+}
+
+// SetPermissionModeRequest is the set_permission_mode control request.
+// The response is a SetPermissionModeResponse.
+type SetPermissionModeRequest struct {
+	ControlRequestBase
+	Mode PermissionMode `json:"mode"`
+}
+
+// SetModelRequest is the set_model control request. An empty Model resets
+// the session to the default model. The response has no payload.
+type SetModelRequest struct {
+	ControlRequestBase
+	Model string `json:"model,omitempty"`
+}
+
+// InterruptRequest is the interrupt control request, which aborts the
+// running turn.
+type InterruptRequest struct {
+	ControlRequestBase
+}
+
+// SetMaxThinkingTokensRequest is the set_max_thinking_tokens control request.
+// A null limit disables extended thinking. The response has no payload.
+type SetMaxThinkingTokensRequest struct {
+	ControlRequestBase
+	MaxThinkingTokens Nullable[int] `json:"max_thinking_tokens"`
+}
+
+// InitializeRequest is the initialize control request, the optional handshake
+// sent before the first user message. It registers hook callbacks and custom
+// agents; the response is an InitializeResponse.
+type InitializeRequest struct {
+	ControlRequestBase
+	Hooks              map[string][]HookMatcher   `json:"hooks,omitempty"`              // Hook event name (e.g. "PreToolUse") to matchers
+	Agents             map[string]AgentDefinition `json:"agents,omitempty"`             // Agent name to definition
+	SDKMCPServers      []string                   `json:"sdkMcpServers,omitempty"`      // Names of MCP servers hosted by the caller
+	JSONSchema         map[string]any             `json:"jsonSchema,omitempty"`         // Schema for structured output
+	SystemPrompt       string                     `json:"systemPrompt,omitempty"`       // Replaces the default system prompt
+	AppendSystemPrompt string                     `json:"appendSystemPrompt,omitempty"` // Appended to the system prompt
+}
+
+// HookMatcher registers hook callbacks for the tools matched by Matcher.
+// The CLI invokes each callback with a hook_callback control request.
+type HookMatcher struct {
+	Matcher         string   `json:"matcher,omitempty"` // Tool name pattern; empty matches all tools
+	HookCallbackIDs []string `json:"hookCallbackIds"`
+	Timeout         int      `json:"timeout,omitempty"` // Seconds
+	Extra           Extra    `json:"-"`                 // Fields not modeled by the type
+}
+
+// AgentDefinition defines a custom agent in an InitializeRequest.
+type AgentDefinition struct {
+	Description string   `json:"description"`
+	Prompt      string   `json:"prompt"`
+	Tools       []string `json:"tools,omitempty"` // Allowed tools; all tools when empty
+	Model       string   `json:"model,omitempty"` // e.g. "sonnet"; inherits the session model when empty
+	Extra       Extra    `json:"-"`               // Fields not modeled by the type
+}
+
+// HookCallbackRequest is the hook_callback control request, sent by the CLI
+// on stdout when a hook registered in the InitializeRequest fires.
+type HookCallbackRequest struct {
+	ControlRequestBase
+	CallbackID string         `json:"callback_id"`
+	Input      map[string]any `json:"input"`                 // Hook input (hook_event_name, tool_name, ...)
+	ToolUseID  string         `json:"tool_use_id,omitempty"` // Tool call that triggered the hook
+}
+
+// UnknownControlRequest holds a control request whose subtype is not recognized.
+// Raw contains the original JSON and is emitted verbatim when encoding.
+type UnknownControlRequest struct {
+	ControlRequestBase
+	Raw json.RawMessage `json:"-"` // Original JSON
 }
 
 // ControlResponseBody is the response payload inside a ControlResponseMessage.
 type ControlResponseBody struct {
 	Subtype   string `json:"subtype"`            // "success" or "error"
 	RequestID string `json:"request_id"`         // Correlation ID
-	Response  any    `json:"response,omitempty"` // PermissionPayload or map
+	Response  any    `json:"response,omitempty"` // Typed response (e.g. PermissionPayload) or map; see DecodeControlResponse
 	Error     string `json:"error,omitempty"`    // Error message (when subtype is "error")
+	Extra     Extra  `json:"-"`                  // Fields not modeled by the type
 }
 
 // PermissionPayload is the inner response for permission prompt control_responses.
 type PermissionPayload struct {
-	Behavior     string `json:"behavior"`               // "allow" or "deny"
-	UpdatedInput any    `json:"updatedInput,omitempty"` // Updated tool input (allow)
-	Message      string `json:"message,omitempty"`      // Denial reason (deny)
+	Behavior           string             `json:"behavior"`                     // "allow" or "deny"
+	UpdatedInput       any                `json:"updatedInput,omitempty"`       // Updated tool input (allow)
+	UpdatedPermissions []PermissionUpdate `json:"updatedPermissions,omitempty"` // Permission updates to apply (allow), e.g. the request's suggestions
+	Message            string             `json:"message,omitempty"`            // Denial reason (deny)
+}
+
+// PermissionUpdate changes the session's permission settings. The CLI offers
+// them as CanUseToolRequest.PermissionSuggestions; sending them back in
+// PermissionPayload.UpdatedPermissions applies them ("always allow").
+// The Type field determines which optional fields are populated.
+type PermissionUpdate struct {
+	Type        PermissionUpdateType        `json:"type"`
+	Rules       []PermissionRuleValue       `json:"rules,omitempty"`       // addRules, replaceRules, removeRules
+	Behavior    string                      `json:"behavior,omitempty"`    // addRules, replaceRules, removeRules: "allow", "deny" or "ask"
+	Mode        PermissionMode              `json:"mode,omitempty"`        // setMode
+	Directories []string                    `json:"directories,omitempty"` // addDirectories, removeDirectories
+	Destination PermissionUpdateDestination `json:"destination"`           // Where the update is stored
+	Extra       Extra                       `json:"-"`                     // Fields not modeled by the type
+}
+
+// PermissionRuleValue is a permission rule such as Bash(npm test), split into
+// the tool name and the optional rule content.
+type PermissionRuleValue struct {
+	ToolName    string `json:"toolName"`
+	RuleContent string `json:"ruleContent,omitempty"` // e.g. a command prefix or path glob; empty matches every call
+	Extra       Extra  `json:"-"`                     // Fields not modeled by the type
+}
+
+// SetPermissionModeResponse is the response to a set_permission_mode request.
+type SetPermissionModeResponse struct {
+	Mode  PermissionMode `json:"mode"`
+	Extra Extra          `json:"-"` // Fields not modeled by the type
+}
+
+// InitializeResponse is the response to an initialize request. It describes
+// the session: available slash commands, agents, output styles and models.
+type InitializeResponse struct {
+	Commands              []SlashCommand `json:"commands"`
+	Agents                []AgentInfo    `json:"agents,omitempty"`
+	OutputStyle           string         `json:"output_style"`
+	AvailableOutputStyles []string       `json:"available_output_styles"`
+	Models                []ModelInfo    `json:"models"`
+	Account               *AccountInfo   `json:"account,omitempty"`
+	Extra                 Extra          `json:"-"` // Fields not modeled by the type
+}
+
+// SlashCommand describes a slash command in an InitializeResponse.
+type SlashCommand struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	ArgumentHint string `json:"argumentHint"`
+	Extra        Extra  `json:"-"` // Fields not modeled by the type
+}
+
+// AgentInfo describes an agent in an InitializeResponse.
+type AgentInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Model       string `json:"model,omitempty"`
+	Extra       Extra  `json:"-"` // Fields not modeled by the type
+}
+
+// ModelInfo describes a selectable model in an InitializeResponse.
+type ModelInfo struct {
+	Value       string `json:"value"` // Value to pass to set_model or --model
+	DisplayName string `json:"displayName"`
+	Description string `json:"description"`
+	Extra       Extra  `json:"-"` // Fields not modeled by the type
+}
+
+// AccountInfo describes the authenticated account in an InitializeResponse.
+type AccountInfo struct {
+	Email            string `json:"email,omitempty"`
+	Organization     string `json:"organization,omitempty"`
+	SubscriptionType string `json:"subscriptionType,omitempty"`
+	TokenSource      string `json:"tokenSource,omitempty"`
+	APIKeySource     string `json:"apiKeySource,omitempty"`
+	Extra            Extra  `json:"-"` // Fields not modeled by the type
 }
 
 // # control_request
@@ -434,14 +842,16 @@ type PermissionPayload struct {
 // Contains a request_id for correlation and a request object with a subtype field.
 // Supported subtypes: set_permission_mode, set_model, interrupt, set_max_thinking_tokens, initialize.
 // The CLI processes control_request messages between turns (after a result is emitted).
+// The CLI also sends can_use_tool and hook_callback requests on stdout.
+// The request object decodes to a typed struct per subtype (e.g. SetPermissionModeRequest).
 //
 // ```json
 // {"type":"control_request","request_id":"aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee","request":{"subtype":"set_permission_mode","mode":"plan"}}
 // ```
 type ControlRequestMessage struct {
 	MessageBase
-	RequestID string         `json:"request_id"` // Correlation ID
-	Request   ControlRequest `json:"request"`    // Request payload (subtype + params)
+	RequestID string           `json:"request_id"` // Correlation ID
+	Request   IsControlRequest `json:"request"`    // Request payload (subtype + params)
 }
 
 // # control_response
@@ -0,0 +1,70 @@
+package ccprotocol
+
+import "strings"
+
+// ResultMessage is implemented by all turn-ending result messages:
+// *ResultSuccessMessage, *ResultErrorMessage, *ResultMaxTurnsMessage and, for
+// subtypes without a dedicated type, *ResultOtherMessage.
+type ResultMessage interface {
+	IsMessage
+	// Common returns the fields shared by all result subtypes.
+	Common() ResultCommon
+	// Outcome classifies how the turn ended.
+	Outcome() Outcome
+}
+
+// Outcome classifies how a turn ended.
+type Outcome string
+
+const (
+	OutcomeSuccess  Outcome = "success"   // Completed normally
+	OutcomeError    Outcome = "error"     // An error ended the turn (including success results with is_error true)
+	OutcomeMaxTurns Outcome = "max_turns" // The --max-turns limit was reached
+	OutcomeUnknown  Outcome = "unknown"   // A result subtype this package does not know
+)
+
+// Common implements ResultMessage for the result types embedding ResultCommon.
+func (c ResultCommon) Common() ResultCommon {
+	return c
+}
+
+// resultErrors returns the errors array of r, which result/success lacks.
+func resultErrors(r ResultMessage) []string {
+	switch m := r.(type) {
+	case *ResultErrorMessage:
+		return m.Errors
+	case *ResultMaxTurnsMessage:
+		return m.Errors
+	case *ResultOtherMessage:
+		return m.Errors
+	}
+	return nil
+}
+
+// Outcome implements ResultMessage. A success result flagged is_error (e.g.
+// after repeated max_tokens API errors) is reported as OutcomeError.
+func (m *ResultSuccessMessage) Outcome() Outcome {
+	if m.IsError {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// Outcome implements ResultMessage.
+func (m *ResultErrorMessage) Outcome() Outcome {
+	return OutcomeError
+}
+
+// Outcome implements ResultMessage.
+func (m *ResultMaxTurnsMessage) Outcome() Outcome {
+	return OutcomeMaxTurns
+}
+
+// Outcome implements ResultMessage. Subtypes starting with "error" and
+// results flagged is_error are reported as OutcomeError.
+func (m *ResultOtherMessage) Outcome() Outcome {
+	if m.IsError || strings.HasPrefix(string(m.Subtype), "error") {
+		return OutcomeError
+	}
+	return OutcomeUnknown
+}
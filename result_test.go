@@ -0,0 +1,71 @@
+package ccprotocol_test
+
+import (
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+func TestResultMessage_Outcome(t *testing.T) {
+	tests := []struct {
+		data string
+		want Outcome
+	}{
+		{`{"type":"result","subtype":"success","is_error":false,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"Hello!","stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"xxx"}`, OutcomeSuccess},
+		{`{"type":"result","subtype":"success","is_error":true,"duration_ms":55,"duration_api_ms":12,"num_turns":1,"result":"API Error","stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"xxx"}`, OutcomeError},
+		{`{"type":"result","subtype":"error_during_execution","is_error":false,"duration_ms":52,"duration_api_ms":18,"num_turns":1,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"xxx","errors":["error message"]}`, OutcomeError},
+		{`{"type":"result","subtype":"error_max_turns","is_error":false,"duration_ms":184,"duration_api_ms":30,"num_turns":2,"stop_reason":null,"session_id":"abc","total_cost_usd":0.00055,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"xxx","errors":[]}`, OutcomeMaxTurns},
+		{`{"type":"result","subtype":"error_max_budget_usd","is_error":true,"duration_ms":10,"duration_api_ms":5,"num_turns":1,"session_id":"abc","total_cost_usd":1.5,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"xxx","errors":[]}`, OutcomeError},
+		{`{"type":"result","subtype":"cancelled","is_error":false,"duration_ms":10,"duration_api_ms":5,"num_turns":1,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"uuid":"xxx"}`, OutcomeUnknown},
+	}
+	for _, tt := range tests {
+		msg, err := DecodeMessage([]byte(tt.data))
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.data, err)
+		}
+		r, ok := msg.(ResultMessage)
+		if !ok {
+			t.Fatalf("%T does not implement ResultMessage", msg)
+		}
+		if got := r.Outcome(); got != tt.want {
+			t.Errorf("%T Outcome() = %q, want %q", msg, got, tt.want)
+		}
+		if c := r.Common(); c.SessionID != "abc" || c.UUID != "xxx" || c.DurationMs == 0 {
+			t.Errorf("%T Common() = %+v", msg, c)
+		}
+	}
+}
+
+func TestResultOtherMessage(t *testing.T) {
+	data := []byte(`{"type":"result","subtype":"error_max_budget_usd","is_error":true,"duration_ms":10,"duration_api_ms":5,"num_turns":3,"session_id":"abc","total_cost_usd":1.5,"usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":1},"modelUsage":{},"permission_denials":[{"tool_name":"Bash","tool_use_id":"toolu_001","tool_input":{}}],"fast_mode_state":"off","uuid":"xxx","errors":["budget exceeded"],"max_budget_usd":1}`)
+
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, ok := msg.(*ResultOtherMessage)
+	if !ok {
+		t.Fatalf("expected *ResultOtherMessage, got %T", msg)
+	}
+	if m.Subtype != "error_max_budget_usd" || len(m.Errors) != 1 {
+		t.Errorf("Subtype = %q, Errors = %v", m.Subtype, m.Errors)
+	}
+	c := m.Common()
+	if c.NumTurns != 3 || c.TotalCostUSD != 1.5 || c.Usage.InputTokens != 10 {
+		t.Errorf("Common() = %+v", c)
+	}
+	if len(c.PermissionDenials) != 1 {
+		t.Errorf("Common() denials = %v", c.PermissionDenials)
+	}
+	if got := string(m.Extra["max_budget_usd"]); got != "1" {
+		t.Errorf("Extra[max_budget_usd] = %s, want 1", got)
+	}
+
+	got, err := EncodeMessage(m)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("round trip mismatch:\n  got:  %s\n  want: %s", got, data)
+	}
+}
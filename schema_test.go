@@ -0,0 +1,67 @@
+package ccprotocol_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hrntknr/claudecodeprotocol/utils"
+)
+
+func loadSchema(t *testing.T) *utils.Schema {
+	t.Helper()
+	js, err := utils.LoadSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js
+}
+
+// Every example in the doc comments of protocol.go is valid. A message
+// section (e.g. "# user") documents several message types, so message
+// examples are validated as any Message.
+func TestSchema_Examples(t *testing.T) {
+	js := loadSchema(t)
+	n := 0
+	for name, examples := range js.Examples() {
+		def := name
+		if strings.HasSuffix(name, "Message") {
+			def = ""
+		}
+		for _, ex := range examples {
+			if err := js.Validate(def, ex); err != nil {
+				t.Errorf("%s example: %v", name, err)
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		t.Fatal("no examples in schema")
+	}
+}
+
+func TestSchema_Rejects(t *testing.T) {
+	js := loadSchema(t)
+	// A result subtype without a dedicated type is a ResultOtherMessage.
+	other := `{"type":"result","subtype":"error_max_budget_usd","is_error":true,"duration_ms":1,"duration_api_ms":1,"num_turns":1,"session_id":"s","total_cost_usd":0,"usage":{"input_tokens":0,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":0},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"u"}`
+	if err := js.ValidateMessage([]byte(other)); err != nil {
+		t.Errorf("ResultOtherMessage: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"unknown type", `{"type":"bogus"}`},
+		{"missing required field", `{"type":"system","subtype":"status","status":null,"uuid":"u","session_id":"s"}`},
+		{"enum value", `{"type":"system","subtype":"status","status":null,"permissionMode":"yolo","uuid":"u","session_id":"s"}`},
+		{"not nullable", `{"type":"user","message":{"role":"user","content":null}}`},
+		{"unknown content block", `{"type":"assistant","message":{"content":[{"type":"image"}],"id":"m","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":0,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":0}},"parent_tool_use_id":null,"session_id":"s","uuid":"u"}`},
+		{"control request subtype", `{"type":"control_request","request_id":"r","request":{"subtype":"bogus"}}`},
+		{"result subtype with a dedicated type", strings.Replace(other, "error_max_budget_usd", "success", 1)},
+	}
+	for _, tt := range tests {
+		if err := js.ValidateMessage([]byte(tt.json)); err == nil {
+			t.Errorf("%s: %s validated, want error", tt.name, tt.json)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package ccprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// StreamAssembler rebuilds an assistant message from the stream_event
+// messages emitted with --include-partial-messages, the way an Anthropic
+// SSE client accumulates a streamed response. Feed it the events of one
+// stream in order; each message_start begins a new message. Events of
+// subagents (StreamEventMessage.ParentToolUseID set) form separate streams.
+//
+// Once a block has stopped, it equals the block of the corresponding
+// assistant message emitted by the CLI.
+type StreamAssembler struct {
+	// OnDelta is called after each content_block_delta is applied, with the
+	// block as assembled so far. The input of a tool_use block is parsed
+	// from the partial JSON with ParsePartialJSON, so it holds the fields
+	// received so far; it is exact once the block stops.
+	OnDelta func(index int, delta ContentDelta, block IsContentBlock)
+
+	// OnBlock is called when content_block_stop completes a block.
+	OnBlock func(index int, block IsContentBlock)
+
+	// OnMessage is called when message_stop completes the message.
+	OnMessage func(body AssistantBody)
+
+	body    AssistantBody
+	started bool
+	done    bool
+	slots   map[int]int              // event index -> position in body.Content
+	partial map[int]*strings.Builder // event index -> accumulated partial_json
+}
+
+// Add decodes the event of m and applies it. Unrecognized events are
+// ignored.
+func (a *StreamAssembler) Add(m *StreamEventMessage) error {
+	ev, err := m.DecodeEvent(Lenient())
+	if err != nil {
+		return err
+	}
+	return a.AddEvent(ev)
+}
+
+// AddEvent applies a decoded stream event.
+func (a *StreamAssembler) AddEvent(ev IsStreamEvent) error {
+	switch e := ev.(type) {
+	case MessageStartEvent:
+		a.body = e.Message
+		a.body.Content = nil
+		a.started = true
+		a.done = false
+		a.slots = map[int]int{}
+		a.partial = map[int]*strings.Builder{}
+	case ContentBlockStartEvent:
+		if !a.started {
+			return fmt.Errorf("stream: %s before message_start", e.Type)
+		}
+		if _, ok := a.slots[e.Index]; ok {
+			return fmt.Errorf("stream: content block %d started twice", e.Index)
+		}
+		a.slots[e.Index] = len(a.body.Content)
+		a.body.Content = append(a.body.Content, e.ContentBlock)
+	case ContentBlockDeltaEvent:
+		i, ok := a.slots[e.Index]
+		if !ok {
+			return fmt.Errorf("stream: %s for unknown content block %d", e.Delta.Type, e.Index)
+		}
+		block, err := a.applyDelta(e.Index, a.body.Content[i], e.Delta)
+		if err != nil {
+			return err
+		}
+		a.body.Content[i] = block
+		if a.OnDelta != nil {
+			a.OnDelta(e.Index, e.Delta, block)
+		}
+	case ContentBlockStopEvent:
+		i, ok := a.slots[e.Index]
+		if !ok {
+			return fmt.Errorf("stream: %s for unknown content block %d", e.Type, e.Index)
+		}
+		if tu, ok := a.body.Content[i].(ToolUseBlock); ok {
+			if p := a.partial[e.Index]; p != nil && p.Len() > 0 {
+				var input map[string]any
+				if err := json.Unmarshal([]byte(p.String()), &input); err != nil {
+					return fmt.Errorf("stream: decode input of tool_use block %d: %w", e.Index, err)
+				}
+				tu.Input = input
+				a.body.Content[i] = tu
+			}
+		}
+		if a.OnBlock != nil {
+			a.OnBlock(e.Index, a.body.Content[i])
+		}
+	case MessageDeltaEvent:
+		if !a.started {
+			return fmt.Errorf("stream: %s before message_start", e.Type)
+		}
+		a.body.StopReason = e.Delta.StopReason
+		a.body.StopSequence = e.Delta.StopSequence
+		mergeUsage(&a.body.Usage, e.Usage)
+	case MessageStopEvent:
+		if !a.started {
+			return fmt.Errorf("stream: %s before message_start", e.Type)
+		}
+		a.done = true
+		if a.OnMessage != nil {
+			a.OnMessage(a.Message())
+		}
+	}
+	return nil
+}
+
+// applyDelta returns block with d applied.
+func (a *StreamAssembler) applyDelta(index int, block IsContentBlock, d ContentDelta) (IsContentBlock, error) {
+	switch b := block.(type) {
+	case TextBlock:
+		if d.Type == DeltaText {
+			b.Text += d.Text
+			return b, nil
+		}
+	case ThinkingBlock:
+		switch d.Type {
+		case DeltaThinking:
+			b.Thinking += d.Thinking
+			return b, nil
+		case DeltaSignature:
+			b.Signature += d.Signature
+			return b, nil
+		}
+	case ToolUseBlock:
+		if d.Type == DeltaInputJSON {
+			p := a.partial[index]
+			if p == nil {
+				p = &strings.Builder{}
+				a.partial[index] = p
+			}
+			p.WriteString(d.PartialJSON)
+			// A malformed prefix keeps the last input; content_block_stop
+			// reports the error.
+			if input, err := ParsePartialJSON(p.String()); err == nil {
+				b.Input = input
+			}
+			return b, nil
+		}
+	case UnknownBlock:
+		// Deltas of unrecognized blocks cannot be applied.
+		return b, nil
+	}
+	return nil, fmt.Errorf("stream: %s for content block %d of another type", d.Type, index)
+}
+
+// mergeUsage applies the cumulative usage of a message_delta event to u.
+// Fields absent from the delta (zero) are kept; unmodeled fields are merged
+// key by key.
+func mergeUsage(u *Usage, d Usage) {
+	if d.InputTokens != 0 {
+		u.InputTokens = d.InputTokens
+	}
+	if d.CacheCreationInputTokens != 0 {
+		u.CacheCreationInputTokens = d.CacheCreationInputTokens
+	}
+	if d.CacheReadInputTokens != 0 {
+		u.CacheReadInputTokens = d.CacheReadInputTokens
+	}
+	if d.OutputTokens != 0 {
+		u.OutputTokens = d.OutputTokens
+	}
+	if d.ServerToolUse != nil {
+		u.ServerToolUse = d.ServerToolUse
+	}
+	if d.ServiceTier != "" {
+		u.ServiceTier = d.ServiceTier
+	}
+	if d.CacheCreation != nil {
+		u.CacheCreation = d.CacheCreation
+	}
+	if len(d.Extra) > 0 {
+		extra := make(Extra, len(u.Extra)+len(d.Extra))
+		maps.Copy(extra, u.Extra)
+		maps.Copy(extra, d.Extra)
+		u.Extra = extra
+	}
+}
+
+// Message returns the message as assembled so far.
+func (a *StreamAssembler) Message() AssistantBody {
+	body := a.body
+	body.Content = append([]IsContentBlock(nil), a.body.Content...)
+	return body
+}
+
+// PartialJSON returns the input JSON received so far for the tool_use block
+// at the given event index. ParsePartialJSON turns it into the input
+// received so far.
+func (a *StreamAssembler) PartialJSON(index int) string {
+	if p := a.partial[index]; p != nil {
+		return p.String()
+	}
+	return ""
+}
+
+// Done reports whether the current message has been completed by
+// message_stop.
+func (a *StreamAssembler) Done() bool {
+	return a.done
+}
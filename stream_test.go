@@ -0,0 +1,247 @@
+package ccprotocol_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// streamEvents is a streamed response with a thinking, a text and a
+// tool_use block, as stream_event lines.
+const streamEvents = `{"type":"stream_event","event":{"type":"message_start","message":{"id":"msg_001","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5-20250929","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u1"}
+{"type":"stream_event","event":{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u2"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me "}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u3"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"think."}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u4"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u5"}
+{"type":"stream_event","event":{"type":"content_block_stop","index":0},"session_id":"abc","parent_tool_use_id":null,"uuid":"u6"}
+{"type":"stream_event","event":{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u7"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Listing "}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u8"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"files."}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u9"}
+{"type":"stream_event","event":{"type":"content_block_stop","index":1},"session_id":"abc","parent_tool_use_id":null,"uuid":"u10"}
+{"type":"stream_event","event":{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_001","name":"Bash","input":{}}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u11"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"command\":"}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u12"}
+{"type":"stream_event","event":{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":" \"ls -la\"}"}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u13"}
+{"type":"stream_event","event":{"type":"content_block_stop","index":2},"session_id":"abc","parent_tool_use_id":null,"uuid":"u14"}
+{"type":"stream_event","event":{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":20}},"session_id":"abc","parent_tool_use_id":null,"uuid":"u15"}
+{"type":"stream_event","event":{"type":"message_stop"},"session_id":"abc","parent_tool_use_id":null,"uuid":"u16"}`
+
+// The assistant messages the CLI emits for streamEvents, one per block.
+const streamAssistant = `{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"Let me think.","signature":"sig"}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"a1"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Listing files."}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"a2"}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_001","name":"Bash","input":{"command":"ls -la"}}],"id":"msg_001","model":"claude-sonnet-4-5-20250929","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":10,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"a3"}`
+
+func decodeLines[T IsMessage](t *testing.T, lines string) []T {
+	t.Helper()
+	var msgs []T
+	for _, line := range strings.Split(lines, "\n") {
+		msg, err := DecodeMessage([]byte(line))
+		if err != nil {
+			t.Fatalf("decode %s: %v", line, err)
+		}
+		msgs = append(msgs, msg.(T))
+	}
+	return msgs
+}
+
+func TestDecodeStreamEvent(t *testing.T) {
+	tests := []struct {
+		json string
+		want IsStreamEvent
+	}{
+		{`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			ContentBlockStartEvent{StreamEventBase: StreamEventBase{Type: EventContentBlockStart}, Index: 1, ContentBlock: TextBlock{ContentBlockBase: ContentBlockBase{Type: BlockText}}}},
+		{`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"a\""}}`,
+			ContentBlockDeltaEvent{StreamEventBase: StreamEventBase{Type: EventContentBlockDelta}, Delta: ContentDelta{Type: DeltaInputJSON, PartialJSON: `{"a"`}}},
+		{`{"type":"content_block_stop","index":2}`,
+			ContentBlockStopEvent{StreamEventBase: StreamEventBase{Type: EventContentBlockStop}, Index: 2}},
+		{`{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"input_tokens":0,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":20}}`,
+			MessageDeltaEvent{StreamEventBase: StreamEventBase{Type: EventMessageDelta}, Delta: MessageDelta{StopReason: NewNullable("end_turn")}, Usage: Usage{OutputTokens: 20}}},
+		{`{"type":"message_stop"}`,
+			MessageStopEvent{StreamEventBase{Type: EventMessageStop}}},
+	}
+	for _, tt := range tests {
+		got, err := DecodeStreamEvent([]byte(tt.json))
+		if err != nil {
+			t.Fatalf("DecodeStreamEvent(%s): %v", tt.json, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecodeStreamEvent(%s) = %#v, want %#v", tt.json, got, tt.want)
+		}
+		b, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if string(b) != tt.json {
+			t.Errorf("round trip:\n  got  %s\n  want %s", b, tt.json)
+		}
+	}
+}
+
+func TestDecodeStreamEvent_Unknown(t *testing.T) {
+	data := []byte(`{"type":"ping"}`)
+	if _, err := DecodeStreamEvent(data); err == nil {
+		t.Error("expected error for unknown event type, got nil")
+	}
+	ev, err := DecodeStreamEvent(data, Lenient())
+	if err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	if u, ok := ev.(UnknownStreamEvent); !ok || string(u.Raw) != string(data) {
+		t.Errorf("lenient = %#v, want UnknownStreamEvent", ev)
+	}
+
+	block := []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"x"}}`)
+	if _, err := DecodeStreamEvent(block); err == nil {
+		t.Error("expected error for unknown content block, got nil")
+	}
+	if _, err := DecodeStreamEvent(block, Lenient()); err != nil {
+		t.Errorf("lenient unknown block: %v", err)
+	}
+}
+
+func TestStreamAssembler(t *testing.T) {
+	var text strings.Builder
+	var blocks []IsContentBlock
+	var inputs []map[string]any
+	var final *AssistantBody
+	a := &StreamAssembler{
+		OnDelta: func(_ int, d ContentDelta, b IsContentBlock) {
+			text.WriteString(d.Text)
+			if tu, ok := b.(ToolUseBlock); ok {
+				inputs = append(inputs, tu.Input)
+			}
+		},
+		OnBlock: func(_ int, b IsContentBlock) { blocks = append(blocks, b) },
+		OnMessage: func(body AssistantBody) {
+			final = &body
+		},
+	}
+	for i, m := range decodeLines[*StreamEventMessage](t, streamEvents) {
+		if err := a.Add(m); err != nil {
+			t.Fatalf("Add(event %d): %v", i, err)
+		}
+		if i == 12 {
+			if got := a.PartialJSON(2); got != `{"command": "ls -la"}` {
+				t.Errorf("PartialJSON(2) = %q", got)
+			}
+		}
+	}
+
+	// Each completed block equals the block of the CLI's assistant message.
+	var want []IsContentBlock
+	for _, m := range decodeLines[*AssistantMessage](t, streamAssistant) {
+		want = append(want, m.Message.Content...)
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("OnBlock blocks = %#v\n  want %#v", blocks, want)
+	}
+	if text.String() != "Listing files." {
+		t.Errorf("text deltas = %q", text.String())
+	}
+	// The tool input is parsed from the partial JSON as it streams.
+	wantInputs := []map[string]any{{}, {"command": "ls -la"}}
+	if !reflect.DeepEqual(inputs, wantInputs) {
+		t.Errorf("OnDelta tool inputs = %v, want %v", inputs, wantInputs)
+	}
+
+	if !a.Done() || final == nil {
+		t.Fatal("message not completed")
+	}
+	got := a.Message()
+	if !reflect.DeepEqual(got.Content, want) {
+		t.Errorf("Message().Content = %#v\n  want %#v", got.Content, want)
+	}
+	if got.ID != "msg_001" || got.StopReason != NewNullable("tool_use") || got.Usage.InputTokens != 10 || got.Usage.OutputTokens != 20 {
+		t.Errorf("Message() = %+v", got)
+	}
+	if !reflect.DeepEqual(*final, got) {
+		t.Errorf("OnMessage body = %+v, want %+v", *final, got)
+	}
+}
+
+func TestStreamAssembler_MessageDeltaUsage(t *testing.T) {
+	events := []*StreamEventMessage{
+		{Event: map[string]any{"type": "message_start", "message": map[string]any{"id": "m", "content": []any{},
+			"usage": map[string]any{"input_tokens": 10, "output_tokens": 1, "cache_creation_input_tokens": 0, "cache_read_input_tokens": 0}}}},
+		{Event: map[string]any{"type": "message_delta", "delta": map[string]any{"stop_reason": "end_turn", "stop_sequence": nil},
+			"usage": map[string]any{"output_tokens": 5, "cache_creation_input_tokens": 30, "service_tier": "standard",
+				"cache_creation": map[string]any{"ephemeral_1h_input_tokens": 0, "ephemeral_5m_input_tokens": 30}, "speed": "standard"}}},
+		{Event: map[string]any{"type": "message_stop"}},
+	}
+	a := &StreamAssembler{}
+	for i, m := range events {
+		if err := a.Add(m); err != nil {
+			t.Fatalf("Add(event %d): %v", i, err)
+		}
+	}
+
+	got := a.Message().Usage
+	if got.InputTokens != 10 || got.OutputTokens != 5 || got.CacheCreationInputTokens != 30 || got.ServiceTier != "standard" {
+		t.Errorf("Usage = %+v", got)
+	}
+	if got.CacheCreation == nil || got.CacheCreation.Ephemeral5mInputTokens != 30 {
+		t.Errorf("Usage.CacheCreation = %+v, want 30 5m tokens", got.CacheCreation)
+	}
+	if string(got.Extra["speed"]) != `"standard"` {
+		t.Errorf("Usage.Extra = %v, want speed", got.Extra)
+	}
+}
+
+// A delta that ends inside a surrogate pair escape reports the input without
+// the partial character.
+func TestStreamAssembler_SplitEscape(t *testing.T) {
+	var inputs []any
+	a := &StreamAssembler{
+		OnDelta: func(_ int, _ ContentDelta, b IsContentBlock) {
+			inputs = append(inputs, b.(ToolUseBlock).Input["text"])
+		},
+	}
+	for i, m := range []*StreamEventMessage{
+		{Event: map[string]any{"type": "message_start", "message": map[string]any{"id": "m", "content": []any{}}}},
+		{Event: map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "tool_use", "id": "t", "name": "Write", "input": map[string]any{}}}},
+		{Event: map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": `{"text":"hi \ud83d`}}},
+		{Event: map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": `\ude`}}},
+		{Event: map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": `00"}`}}},
+	} {
+		if err := a.Add(m); err != nil {
+			t.Fatalf("Add(event %d): %v", i, err)
+		}
+	}
+	if want := []any{"hi ", "hi ", "hi 😀"}; !reflect.DeepEqual(inputs, want) {
+		t.Errorf("OnDelta inputs = %q, want %q", inputs, want)
+	}
+}
+
+func TestStreamAssembler_Errors(t *testing.T) {
+	start := &StreamEventMessage{Event: map[string]any{"type": "message_start", "message": map[string]any{"id": "m", "content": []any{}}}}
+	text := &StreamEventMessage{Event: map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}}}
+	tests := []struct {
+		name   string
+		events []*StreamEventMessage
+	}{
+		{"block before message_start", []*StreamEventMessage{text}},
+		{"delta for unknown block", []*StreamEventMessage{start,
+			{Event: map[string]any{"type": "content_block_delta", "index": 1, "delta": map[string]any{"type": "text_delta", "text": "x"}}}}},
+		{"delta of another type", []*StreamEventMessage{start, text,
+			{Event: map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": "{"}}}}},
+		{"invalid tool input", []*StreamEventMessage{start,
+			{Event: map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "tool_use", "id": "t", "name": "Bash", "input": map[string]any{}}}},
+			{Event: map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": "{\"a\":"}}},
+			{Event: map[string]any{"type": "content_block_stop", "index": 0}}}},
+	}
+	for _, tt := range tests {
+		a := &StreamAssembler{}
+		var err error
+		for _, m := range tt.events {
+			if err = a.Add(m); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
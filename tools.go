@@ -0,0 +1,518 @@
+package ccprotocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ---------------------------------------------------------------------------
+// Tool names
+// ---------------------------------------------------------------------------
+
+// Names of the built-in tools, as they appear in ToolUseBlock.Name and
+// CanUseToolRequest.ToolName.
+const (
+	ToolBash            = "Bash"
+	ToolRead            = "Read"
+	ToolWrite           = "Write"
+	ToolEdit            = "Edit"
+	ToolGlob            = "Glob"
+	ToolGrep            = "Grep"
+	ToolNotebookEdit    = "NotebookEdit"
+	ToolTodoWrite       = "TodoWrite"
+	ToolTask            = "Task"
+	ToolTaskCreate      = "TaskCreate"
+	ToolTaskList        = "TaskList"
+	ToolTaskGet         = "TaskGet"
+	ToolTaskUpdate      = "TaskUpdate"
+	ToolAskUserQuestion = "AskUserQuestion"
+	ToolEnterPlanMode   = "EnterPlanMode"
+	ToolExitPlanMode    = "ExitPlanMode"
+	ToolTeamCreate      = "TeamCreate"
+	ToolTeamDelete      = "TeamDelete"
+	ToolSendMessage     = "SendMessage"
+	ToolWebFetch        = "WebFetch"
+	ToolWebSearch       = "WebSearch"
+)
+
+// ---------------------------------------------------------------------------
+// Tool inputs
+// ---------------------------------------------------------------------------
+
+// BashInput is the input of the Bash tool.
+type BashInput struct {
+	Command         string `json:"command"`
+	Description     string `json:"description,omitempty"`
+	Timeout         int    `json:"timeout,omitempty"` // Timeout (ms)
+	RunInBackground bool   `json:"run_in_background,omitempty"`
+}
+
+// ReadInput is the input of the Read tool.
+type ReadInput struct {
+	FilePath string `json:"file_path"`
+	Offset   int    `json:"offset,omitempty"` // First line to read
+	Limit    int    `json:"limit,omitempty"`  // Number of lines to read
+}
+
+// WriteInput is the input of the Write tool.
+type WriteInput struct {
+	FilePath string `json:"file_path"`
+	Content  string `json:"content"`
+}
+
+// EditInput is the input of the Edit tool.
+type EditInput struct {
+	FilePath   string `json:"file_path"`
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+// GlobInput is the input of the Glob tool.
+type GlobInput struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path,omitempty"`
+}
+
+// GrepInput is the input of the Grep tool.
+type GrepInput struct {
+	Pattern    string `json:"pattern"`
+	Path       string `json:"path,omitempty"`
+	Glob       string `json:"glob,omitempty"`        // File filter
+	OutputMode string `json:"output_mode,omitempty"` // "content", "files_with_matches" or "count"
+}
+
+// NotebookEditInput is the input of the NotebookEdit tool.
+type NotebookEditInput struct {
+	NotebookPath string `json:"notebook_path"`
+	CellID       string `json:"cell_id,omitempty"`
+	CellType     string `json:"cell_type,omitempty"` // "code" or "markdown"
+	NewSource    string `json:"new_source"`
+	EditMode     string `json:"edit_mode,omitempty"` // "replace", "insert" or "delete"
+}
+
+// TodoWriteInput is the input of the TodoWrite tool.
+type TodoWriteInput struct {
+	Todos []Todo `json:"todos"`
+}
+
+// Todo is a single TodoWrite item.
+type Todo struct {
+	Content    string `json:"content"`
+	Status     string `json:"status"` // "pending", "in_progress" or "completed"
+	ActiveForm string `json:"activeForm"`
+}
+
+// TaskInput is the input of the Task tool, which spawns a subagent or teammate.
+type TaskInput struct {
+	Description  string `json:"description"`
+	Prompt       string `json:"prompt"`
+	SubagentType string `json:"subagent_type"`
+	TeamName     string `json:"team_name,omitempty"` // Set when spawning a teammate
+	Name         string `json:"name,omitempty"`      // Teammate name
+}
+
+// TaskCreateInput is the input of the TaskCreate tool.
+type TaskCreateInput struct {
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+	ActiveForm  string `json:"activeForm,omitempty"`
+}
+
+// TaskListInput is the input of the TaskList tool (no parameters).
+type TaskListInput struct{}
+
+// TaskGetInput is the input of the TaskGet tool.
+type TaskGetInput struct {
+	TaskID string `json:"taskId"`
+}
+
+// TaskUpdateInput is the input of the TaskUpdate tool.
+type TaskUpdateInput struct {
+	TaskID      string `json:"taskId"`
+	Status      string `json:"status,omitempty"`
+	Subject     string `json:"subject,omitempty"`
+	Description string `json:"description,omitempty"`
+	ActiveForm  string `json:"activeForm,omitempty"`
+}
+
+// AskUserQuestionInput is the input of the AskUserQuestion tool.
+// Answers is set by the permission handler in updatedInput, keyed by question text.
+type AskUserQuestionInput struct {
+	Questions []Question        `json:"questions"`
+	Answers   map[string]string `json:"answers,omitempty"`
+}
+
+// Question is a single AskUserQuestion question.
+type Question struct {
+	Question    string           `json:"question"`
+	Header      string           `json:"header"`
+	MultiSelect bool             `json:"multiSelect"`
+	Options     []QuestionOption `json:"options"`
+}
+
+// QuestionOption is a selectable answer of a Question.
+type QuestionOption struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+// EnterPlanModeInput is the input of the EnterPlanMode tool (no parameters).
+type EnterPlanModeInput struct{}
+
+// ExitPlanModeInput is the input of the ExitPlanMode tool.
+type ExitPlanModeInput struct {
+	Plan string `json:"plan,omitempty"`
+}
+
+// TeamCreateInput is the input of the TeamCreate tool.
+type TeamCreateInput struct {
+	TeamName    string `json:"team_name"`
+	Description string `json:"description,omitempty"`
+}
+
+// TeamDeleteInput is the input of the TeamDelete tool (no parameters).
+type TeamDeleteInput struct{}
+
+// SendMessageInput is the input of the SendMessage tool.
+type SendMessageInput struct {
+	Type      string `json:"type"` // e.g. "message"
+	Recipient string `json:"recipient"`
+	Content   string `json:"content"`
+	Summary   string `json:"summary,omitempty"`
+}
+
+// WebFetchInput is the input of the WebFetch tool.
+type WebFetchInput struct {
+	URL    string `json:"url"`
+	Prompt string `json:"prompt"`
+}
+
+// WebSearchInput is the input of the WebSearch tool.
+type WebSearchInput struct {
+	Query string `json:"query"`
+}
+
+// newToolInput returns a pointer to a zero input struct for the named tool,
+// or nil if the tool is not modeled.
+func newToolInput(name string) any {
+	switch name {
+	case ToolBash:
+		return &BashInput{}
+	case ToolRead:
+		return &ReadInput{}
+	case ToolWrite:
+		return &WriteInput{}
+	case ToolEdit:
+		return &EditInput{}
+	case ToolGlob:
+		return &GlobInput{}
+	case ToolGrep:
+		return &GrepInput{}
+	case ToolNotebookEdit:
+		return &NotebookEditInput{}
+	case ToolTodoWrite:
+		return &TodoWriteInput{}
+	case ToolTask:
+		return &TaskInput{}
+	case ToolTaskCreate:
+		return &TaskCreateInput{}
+	case ToolTaskList:
+		return &TaskListInput{}
+	case ToolTaskGet:
+		return &TaskGetInput{}
+	case ToolTaskUpdate:
+		return &TaskUpdateInput{}
+	case ToolAskUserQuestion:
+		return &AskUserQuestionInput{}
+	case ToolEnterPlanMode:
+		return &EnterPlanModeInput{}
+	case ToolExitPlanMode:
+		return &ExitPlanModeInput{}
+	case ToolTeamCreate:
+		return &TeamCreateInput{}
+	case ToolTeamDelete:
+		return &TeamDeleteInput{}
+	case ToolSendMessage:
+		return &SendMessageInput{}
+	case ToolWebFetch:
+		return &WebFetchInput{}
+	case ToolWebSearch:
+		return &WebSearchInput{}
+	}
+	return nil
+}
+
+// DecodeToolInput converts the input of the named tool to its typed struct
+// (e.g. *BashInput for "Bash"). For tools without a struct (MCP tools, newer
+// built-ins) it returns input unchanged as map[string]any.
+func DecodeToolInput(name string, input map[string]any) (any, error) {
+	v := newToolInput(name)
+	if v == nil {
+		return input, nil
+	}
+	if err := convert(input, v); err != nil {
+		return nil, fmt.Errorf("decode %s input: %w", name, err)
+	}
+	return v, nil
+}
+
+// DecodeInput returns the typed input of the tool call. See DecodeToolInput.
+func (b ToolUseBlock) DecodeInput() (any, error) {
+	return DecodeToolInput(b.Name, b.Input)
+}
+
+// DecodeInput returns the typed input of a can_use_tool request.
+// See DecodeToolInput.
+func (r CanUseToolRequest) DecodeInput() (any, error) {
+	return DecodeToolInput(r.ToolName, r.Input)
+}
+
+// ---------------------------------------------------------------------------
+// Tool results
+// ---------------------------------------------------------------------------
+
+// BashResult is the tool_use_result of the Bash tool.
+type BashResult struct {
+	Stdout           string `json:"stdout"`
+	Stderr           string `json:"stderr"`
+	Interrupted      bool   `json:"interrupted"`
+	IsImage          bool   `json:"isImage"`
+	NoOutputExpected bool   `json:"noOutputExpected"`
+}
+
+// ReadResult is the tool_use_result of the Read tool.
+type ReadResult struct {
+	Type string         `json:"type"` // "text" for text files
+	File ReadResultFile `json:"file"`
+}
+
+// ReadResultFile is the file section of a ReadResult.
+type ReadResultFile struct {
+	FilePath   string `json:"filePath"`
+	Content    string `json:"content"`
+	NumLines   int    `json:"numLines"`
+	StartLine  int    `json:"startLine"`
+	TotalLines int    `json:"totalLines"`
+}
+
+// WriteResult is the tool_use_result of the Write tool.
+type WriteResult struct {
+	Type            string           `json:"type"` // "create" or "update"
+	FilePath        string           `json:"filePath"`
+	Content         string           `json:"content"`
+	StructuredPatch []PatchHunk      `json:"structuredPatch"`
+	OriginalFile    Nullable[string] `json:"originalFile"` // null when the file was created
+}
+
+// EditResult is the tool_use_result of the Edit tool.
+type EditResult struct {
+	FilePath        string      `json:"filePath"`
+	OldString       string      `json:"oldString"`
+	NewString       string      `json:"newString"`
+	OriginalFile    string      `json:"originalFile"`
+	StructuredPatch []PatchHunk `json:"structuredPatch"`
+	UserModified    bool        `json:"userModified"`
+	ReplaceAll      bool        `json:"replaceAll"`
+}
+
+// PatchHunk is a unified diff hunk in WriteResult and EditResult.
+type PatchHunk struct {
+	OldStart int      `json:"oldStart"`
+	OldLines int      `json:"oldLines"`
+	NewStart int      `json:"newStart"`
+	NewLines int      `json:"newLines"`
+	Lines    []string `json:"lines"` // Lines prefixed with " ", "-" or "+"
+}
+
+// GlobResult is the tool_use_result of the Glob tool.
+type GlobResult struct {
+	Filenames  []string `json:"filenames"`
+	DurationMs float64  `json:"durationMs"`
+	NumFiles   int      `json:"numFiles"`
+	Truncated  bool     `json:"truncated"`
+}
+
+// GrepResult is the tool_use_result of the Grep tool.
+type GrepResult struct {
+	Mode      string   `json:"mode"` // Output mode of the search
+	Filenames []string `json:"filenames"`
+	NumFiles  int      `json:"numFiles"`
+	Content   string   `json:"content,omitempty"`  // content mode only
+	NumLines  int      `json:"numLines,omitempty"` // content mode only
+}
+
+// NotebookEditResult is the tool_use_result of the NotebookEdit tool.
+type NotebookEditResult struct {
+	NewSource string `json:"new_source"`
+	CellID    string `json:"cell_id,omitempty"`
+	CellType  string `json:"cell_type"`
+	Language  string `json:"language"`
+	EditMode  string `json:"edit_mode"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TodoWriteResult is the tool_use_result of the TodoWrite tool.
+type TodoWriteResult struct {
+	OldTodos []Todo `json:"oldTodos"`
+	NewTodos []Todo `json:"newTodos"`
+}
+
+// AskUserQuestionResult is the tool_use_result of the AskUserQuestion tool.
+type AskUserQuestionResult struct {
+	Questions []Question        `json:"questions"`
+	Answers   map[string]string `json:"answers"` // Keyed by question text
+}
+
+// ExitPlanModeResult is the tool_use_result of the ExitPlanMode tool.
+type ExitPlanModeResult struct {
+	Plan    string `json:"plan"`
+	IsAgent bool   `json:"isAgent"`
+}
+
+// TeamCreateResult is the tool_use_result of the TeamCreate tool.
+type TeamCreateResult struct {
+	TeamName     string `json:"team_name"`
+	TeamFilePath string `json:"team_file_path"`
+	LeadAgentID  string `json:"lead_agent_id"`
+}
+
+// TeamDeleteResult is the tool_use_result of the TeamDelete tool.
+type TeamDeleteResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// WebFetchResult is the tool_use_result of the WebFetch tool.
+type WebFetchResult struct {
+	Bytes      int     `json:"bytes"`
+	Code       int     `json:"code"` // HTTP status code
+	CodeText   string  `json:"codeText"`
+	Result     string  `json:"result"`
+	DurationMs float64 `json:"durationMs"`
+	URL        string  `json:"url"`
+}
+
+// WebSearchResult is the tool_use_result of the WebSearch tool.
+type WebSearchResult struct {
+	Query           string  `json:"query"`
+	Results         []any   `json:"results"` // Result objects or summary strings
+	DurationSeconds float64 `json:"durationSeconds"`
+	SearchCount     int     `json:"searchCount"`
+}
+
+// newToolResult returns a pointer to a zero result struct for the named tool,
+// or nil if the tool's result is not modeled.
+func newToolResult(name string) any {
+	switch name {
+	case ToolBash:
+		return &BashResult{}
+	case ToolRead:
+		return &ReadResult{}
+	case ToolWrite:
+		return &WriteResult{}
+	case ToolEdit:
+		return &EditResult{}
+	case ToolGlob:
+		return &GlobResult{}
+	case ToolGrep:
+		return &GrepResult{}
+	case ToolNotebookEdit:
+		return &NotebookEditResult{}
+	case ToolTodoWrite:
+		return &TodoWriteResult{}
+	case ToolAskUserQuestion:
+		return &AskUserQuestionResult{}
+	case ToolExitPlanMode:
+		return &ExitPlanModeResult{}
+	case ToolTeamCreate:
+		return &TeamCreateResult{}
+	case ToolTeamDelete:
+		return &TeamDeleteResult{}
+	case ToolWebFetch:
+		return &WebFetchResult{}
+	case ToolWebSearch:
+		return &WebSearchResult{}
+	}
+	return nil
+}
+
+// DecodeToolResult converts the tool_use_result of the named tool to its
+// typed struct (e.g. *BashResult for "Bash"). Failed tool calls report a
+// string (e.g. "Error: ..."), which is returned unchanged, as are null and
+// results of tools without a struct.
+func DecodeToolResult(name string, result any) (any, error) {
+	m, ok := result.(map[string]any)
+	if !ok {
+		return result, nil
+	}
+	v := newToolResult(name)
+	if v == nil {
+		return m, nil
+	}
+	if err := convert(m, v); err != nil {
+		return nil, fmt.Errorf("decode %s result: %w", name, err)
+	}
+	return v, nil
+}
+
+// DecodeResult returns the typed tool_use_result of the call.
+// See DecodeToolResult.
+func (tc ToolCall) DecodeResult() (any, error) {
+	return DecodeToolResult(tc.Use.Name, tc.ToolUseResult)
+}
+
+// ToolResultDecoder decodes tool_use_result payloads by correlating each
+// tool_result with the tool_use that produced it. Pass every output message
+// to Observe, then call Decode for user(tool_result) messages.
+type ToolResultDecoder struct {
+	names map[string]string // tool_use ID -> tool name
+}
+
+// Observe records the tool_use blocks of assistant messages.
+func (d *ToolResultDecoder) Observe(m IsMessage) {
+	am, ok := m.(*AssistantMessage)
+	if !ok {
+		return
+	}
+	for _, b := range am.Message.Content {
+		if tu, ok := b.(ToolUseBlock); ok {
+			if d.names == nil {
+				d.names = make(map[string]string)
+			}
+			d.names[tu.ID] = tu.Name
+		}
+	}
+}
+
+// ToolName returns the name of the tool that issued toolUseID.
+func (d *ToolResultDecoder) ToolName(toolUseID string) (string, bool) {
+	name, ok := d.names[toolUseID]
+	return name, ok
+}
+
+// Decode returns the typed tool_use_result of m along with the name of the
+// tool it belongs to. It is an error if no tool_use with the ID of m's
+// tool_result has been observed.
+func (d *ToolResultDecoder) Decode(m *UserToolResultMessage) (name string, result any, err error) {
+	if len(m.Message.Content) == 0 {
+		return "", nil, errors.New("decode tool result: message has no tool_result block")
+	}
+	id := m.Message.Content[0].ToolUseID
+	name, ok := d.names[id]
+	if !ok {
+		return "", nil, fmt.Errorf("decode tool result: unknown tool_use_id %q", id)
+	}
+	result, err = DecodeToolResult(name, m.ToolUseResult)
+	return name, result, err
+}
+
+// convert copies a decoded JSON value into the typed value pointed to by dst.
+func convert(src any, dst any) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
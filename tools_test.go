@@ -0,0 +1,169 @@
+package ccprotocol_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+func TestToolUseBlock_DecodeInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]any
+		want  any
+	}{
+		{ToolBash, map[string]any{"command": "echo hello", "description": "Print"}, &BashInput{Command: "echo hello", Description: "Print"}},
+		{ToolRead, map[string]any{"file_path": "/tmp/a.txt"}, &ReadInput{FilePath: "/tmp/a.txt"}},
+		{ToolWrite, map[string]any{"file_path": "/tmp/a.txt", "content": "hi"}, &WriteInput{FilePath: "/tmp/a.txt", Content: "hi"}},
+		{ToolEdit, map[string]any{"file_path": "/tmp/a.txt", "old_string": "a", "new_string": "b"}, &EditInput{FilePath: "/tmp/a.txt", OldString: "a", NewString: "b"}},
+		{ToolGlob, map[string]any{"pattern": "*.txt"}, &GlobInput{Pattern: "*.txt"}},
+		{ToolGrep, map[string]any{"pattern": "target", "path": "/tmp"}, &GrepInput{Pattern: "target", Path: "/tmp"}},
+		{ToolNotebookEdit, map[string]any{"notebook_path": "/tmp/n.ipynb", "cell_id": "cell-1", "cell_type": "code", "new_source": "print('world')", "edit_mode": "insert"},
+			&NotebookEditInput{NotebookPath: "/tmp/n.ipynb", CellID: "cell-1", CellType: "code", NewSource: "print('world')", EditMode: "insert"}},
+		{ToolTodoWrite, map[string]any{"todos": []any{map[string]any{"content": "First task", "status": "in_progress", "activeForm": "Working on first task"}}},
+			&TodoWriteInput{Todos: []Todo{{Content: "First task", Status: "in_progress", ActiveForm: "Working on first task"}}}},
+		{ToolTask, map[string]any{"description": "Test teammate", "prompt": "Say hello", "subagent_type": "general-purpose", "team_name": "team", "name": "worker-1"},
+			&TaskInput{Description: "Test teammate", Prompt: "Say hello", SubagentType: "general-purpose", TeamName: "team", Name: "worker-1"}},
+		{ToolTaskCreate, map[string]any{"subject": "Test task", "description": "desc", "activeForm": "Creating"}, &TaskCreateInput{Subject: "Test task", Description: "desc", ActiveForm: "Creating"}},
+		{ToolTaskList, map[string]any{}, &TaskListInput{}},
+		{ToolTaskGet, map[string]any{"taskId": "1"}, &TaskGetInput{TaskID: "1"}},
+		{ToolTaskUpdate, map[string]any{"taskId": "1", "status": "completed"}, &TaskUpdateInput{TaskID: "1", Status: "completed"}},
+		{ToolAskUserQuestion, map[string]any{
+			"questions": []any{map[string]any{
+				"question": "Which color?", "header": "Color", "multiSelect": false,
+				"options": []any{map[string]any{"label": "Red", "description": "Red color"}},
+			}},
+			"answers": map[string]any{"Which color?": "Red"},
+		}, &AskUserQuestionInput{
+			Questions: []Question{{Question: "Which color?", Header: "Color", Options: []QuestionOption{{Label: "Red", Description: "Red color"}}}},
+			Answers:   map[string]string{"Which color?": "Red"},
+		}},
+		{ToolEnterPlanMode, map[string]any{}, &EnterPlanModeInput{}},
+		{ToolExitPlanMode, map[string]any{}, &ExitPlanModeInput{}},
+		{ToolTeamCreate, map[string]any{"team_name": "team", "description": "Protocol test team"}, &TeamCreateInput{TeamName: "team", Description: "Protocol test team"}},
+		{ToolTeamDelete, map[string]any{}, &TeamDeleteInput{}},
+		{ToolSendMessage, map[string]any{"type": "message", "recipient": "agent", "content": "Hello", "summary": "Test"}, &SendMessageInput{Type: "message", Recipient: "agent", Content: "Hello", Summary: "Test"}},
+		{ToolWebFetch, map[string]any{"url": "http://example.com", "prompt": "What?"}, &WebFetchInput{URL: "http://example.com", Prompt: "What?"}},
+		{ToolWebSearch, map[string]any{"query": "protocol test query"}, &WebSearchInput{Query: "protocol test query"}},
+		// Unknown tools fall back to the raw map.
+		{"mcp__server__tool", map[string]any{"x": 1.0}, map[string]any{"x": 1.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToolUseBlock{Name: tt.name, Input: tt.input}.DecodeInput()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeInput() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanUseToolRequest_DecodeInput(t *testing.T) {
+	r := CanUseToolRequest{ControlRequestBase: ControlRequestBase{Subtype: ControlCanUseTool}, ToolName: ToolBash, Input: map[string]any{"command": "ls"}}
+	got, err := r.DecodeInput()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if in, ok := got.(*BashInput); !ok || in.Command != "ls" {
+		t.Errorf("DecodeInput() = %#v, want &BashInput{Command: \"ls\"}", got)
+	}
+}
+
+func TestDecodeToolInput_TypeMismatch(t *testing.T) {
+	_, err := DecodeToolInput(ToolBash, map[string]any{"command": 1.0})
+	if err == nil {
+		t.Fatal("expected error for non-string command, got nil")
+	}
+}
+
+func TestDecodeToolResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		result any
+		want   any
+	}{
+		{ToolBash, map[string]any{"stdout": "step-one", "stderr": "", "interrupted": false, "isImage": false, "noOutputExpected": false}, &BashResult{Stdout: "step-one"}},
+		{ToolRead, map[string]any{"type": "text", "file": map[string]any{"filePath": "/tmp/test.txt", "content": "file-content", "numLines": 1.0, "startLine": 1.0, "totalLines": 1.0}},
+			&ReadResult{Type: "text", File: ReadResultFile{FilePath: "/tmp/test.txt", Content: "file-content", NumLines: 1, StartLine: 1, TotalLines: 1}}},
+		{ToolWebSearch, map[string]any{"query": "protocol test query", "results": []any{"Search completed."}, "durationSeconds": 0.5, "searchCount": 0.0},
+			&WebSearchResult{Query: "protocol test query", Results: []any{"Search completed."}, DurationSeconds: 0.5}},
+		{ToolAskUserQuestion, map[string]any{"questions": []any{}, "answers": map[string]any{"Which color?": "Red"}},
+			&AskUserQuestionResult{Questions: []Question{}, Answers: map[string]string{"Which color?": "Red"}}},
+		{ToolTeamCreate, map[string]any{"team_name": "team", "team_file_path": "/home/u/.claude/teams/team/config.json", "lead_agent_id": "team-lead@team"},
+			&TeamCreateResult{TeamName: "team", TeamFilePath: "/home/u/.claude/teams/team/config.json", LeadAgentID: "team-lead@team"}},
+		{ToolTeamDelete, map[string]any{"success": true, "message": "Team deleted"}, &TeamDeleteResult{Success: true, Message: "Team deleted"}},
+		// Failed calls report a string, which is returned unchanged.
+		{ToolTeamDelete, "Error: No such tool available: TeamDelete", "Error: No such tool available: TeamDelete"},
+		{ToolBash, nil, nil},
+		// Unmodeled tools fall back to the raw map.
+		{ToolTaskList, map[string]any{"tasks": []any{}}, map[string]any{"tasks": []any{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeToolResult(tt.name, tt.result)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeToolResult() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolResultDecoder(t *testing.T) {
+	var d ToolResultDecoder
+	r := NewReader(strings.NewReader(`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_001","name":"Bash","input":{"command":"echo hi"}}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u1"}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_001","content":"hi"}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"u2","tool_use_result":{"stdout":"hi","stderr":"","interrupted":false,"isImage":false,"noOutputExpected":false}}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_999","content":"?"}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"u3","tool_use_result":{}}
+`))
+	var results []*UserToolResultMessage
+	for {
+		msg, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.Observe(msg)
+		if m, ok := msg.(*UserToolResultMessage); ok {
+			results = append(results, m)
+		}
+	}
+
+	name, result, err := d.Decode(results[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != ToolBash {
+		t.Errorf("name = %q, want %q", name, ToolBash)
+	}
+	if br, ok := result.(*BashResult); !ok || br.Stdout != "hi" {
+		t.Errorf("result = %#v, want &BashResult{Stdout: \"hi\"}", result)
+	}
+
+	if _, _, err := d.Decode(results[1]); err == nil {
+		t.Error("expected error for unknown tool_use_id, got nil")
+	}
+}
+
+func TestToolCall_DecodeResult(t *testing.T) {
+	turn, err := ReadTurn(NewReader(strings.NewReader(turnStream)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := turn.ToolCalls()[0].DecodeResult()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "Error: ls: permission denied" {
+		t.Errorf("DecodeResult() = %#v, want the error string", got)
+	}
+}
@@ -0,0 +1,185 @@
+package ccprotocol
+
+// TreeNode is one level of a conversation: the top level of a session or
+// turn, or the messages of a subagent. The CLI marks subagent messages with
+// parent_tool_use_id, the ID of the Task tool_use that spawned the subagent.
+type TreeNode struct {
+	ToolUse  *ToolUseBlock    // The tool_use the subagent runs under; nil for the root (only ID is set if it was not received)
+	Result   *ToolResultBlock // The tool_result of ToolUse; nil until received
+	Messages []IsMessage      // Messages at this level in arrival order
+	Children []*TreeNode      // Subagents spawned at this level, in the order of their tool_use blocks
+
+	usage  []Usage // usage of each API message at this level
+	lastID string  // message ID of the current run of assistant messages
+}
+
+// Assistant returns the assistant messages at this level.
+func (n *TreeNode) Assistant() []*AssistantMessage {
+	var msgs []*AssistantMessage
+	for _, m := range n.Messages {
+		if am, ok := m.(*AssistantMessage); ok {
+			msgs = append(msgs, am)
+		}
+	}
+	return msgs
+}
+
+// Usage returns the token usage of the assistant messages of the subtree,
+// including its children. The CLI emits one assistant message per content
+// block; consecutive assistant messages at a level with the same message ID
+// are one API message and are counted once, with the usage of the last of
+// them.
+func (n *TreeNode) Usage() Usage {
+	var u Usage
+	for _, d := range n.usage {
+		addUsage(&u, d)
+	}
+	for _, c := range n.Children {
+		addUsage(&u, c.Usage())
+	}
+	return u
+}
+
+// addUsage adds the token counts of d to u.
+func addUsage(u *Usage, d Usage) {
+	u.InputTokens += d.InputTokens
+	u.CacheCreationInputTokens += d.CacheCreationInputTokens
+	u.CacheReadInputTokens += d.CacheReadInputTokens
+	u.OutputTokens += d.OutputTokens
+	if d.ServerToolUse != nil {
+		if u.ServerToolUse == nil {
+			u.ServerToolUse = &ServerToolUse{}
+		}
+		u.ServerToolUse.WebSearchRequests += d.ServerToolUse.WebSearchRequests
+		u.ServerToolUse.WebFetchRequests += d.ServerToolUse.WebFetchRequests
+	}
+}
+
+// Walk calls fn for n and each node below it, depth first, with the depth
+// of the node relative to n.
+func (n *TreeNode) Walk(fn func(node *TreeNode, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *TreeNode) walk(fn func(*TreeNode, int), depth int) {
+	fn(n, depth)
+	for _, c := range n.Children {
+		c.walk(fn, depth+1)
+	}
+}
+
+// MessageTree builds the subagent hierarchy of a conversation from decoded
+// messages. Add messages in arrival order; Root holds the top level.
+//
+// A Task tool_use starts a child node, and messages whose parent_tool_use_id
+// names a tool_use are placed in that tool_use's node. Messages naming a
+// tool_use that was not received get a node below the root.
+type MessageTree struct {
+	Root TreeNode
+
+	nodes map[string]*TreeNode // tool_use ID -> subagent node
+	uses  map[string]treeToolUse
+}
+
+// treeToolUse is a received tool_use block and the node it arrived in.
+type treeToolUse struct {
+	block ToolUseBlock
+	node  *TreeNode
+}
+
+// BuildMessageTree returns the tree of msgs.
+func BuildMessageTree(msgs []IsMessage) *MessageTree {
+	t := &MessageTree{}
+	for _, m := range msgs {
+		t.Add(m)
+	}
+	return t
+}
+
+// Add places m in the tree.
+func (t *MessageTree) Add(m IsMessage) {
+	n := &t.Root
+	if p := parentToolUseID(m); p.Valid {
+		n = t.node(p.Value)
+	}
+	n.Messages = append(n.Messages, m)
+
+	switch m := m.(type) {
+	case *AssistantMessage:
+		if len(n.usage) > 0 && m.Message.ID == n.lastID {
+			n.usage[len(n.usage)-1] = m.Message.Usage
+		} else {
+			n.usage = append(n.usage, m.Message.Usage)
+		}
+		n.lastID = m.Message.ID
+		for _, b := range m.Message.Content {
+			tu, ok := b.(ToolUseBlock)
+			if !ok {
+				continue
+			}
+			if t.uses == nil {
+				t.uses = make(map[string]treeToolUse)
+			}
+			t.uses[tu.ID] = treeToolUse{block: tu, node: n}
+			if _, ok := t.nodes[tu.ID]; ok || tu.Name == ToolTask {
+				t.node(tu.ID).ToolUse = &tu
+			}
+		}
+	case *StreamEventMessage:
+		// Stream events interleave with the assistant messages of a response.
+	case *UserToolResultMessage:
+		n.lastID = ""
+		for i := range m.Message.Content {
+			b := &m.Message.Content[i]
+			if c, ok := t.nodes[b.ToolUseID]; ok {
+				c.Result = b
+			}
+		}
+	default:
+		n.lastID = ""
+	}
+}
+
+// node returns the subagent node of the tool_use id, creating it below the
+// node the tool_use arrived in (or the root) if needed.
+func (t *MessageTree) node(id string) *TreeNode {
+	if n, ok := t.nodes[id]; ok {
+		return n
+	}
+	parent := &t.Root
+	n := &TreeNode{ToolUse: &ToolUseBlock{ContentBlockBase: ContentBlockBase{Type: BlockToolUse}, ID: id}}
+	if u, ok := t.uses[id]; ok {
+		parent = u.node
+		n.ToolUse = &u.block
+	}
+	if t.nodes == nil {
+		t.nodes = make(map[string]*TreeNode)
+	}
+	t.nodes[id] = n
+	parent.Children = append(parent.Children, n)
+	return n
+}
+
+// Node returns the subagent node of the tool_use id, or nil if no message
+// belongs to it and it is not a Task tool_use.
+func (t *MessageTree) Node(id string) *TreeNode {
+	return t.nodes[id]
+}
+
+// parentToolUseID returns the parent_tool_use_id of m, or null for message
+// types without one.
+func parentToolUseID(m IsMessage) Nullable[string] {
+	switch m := m.(type) {
+	case *AssistantMessage:
+		return m.ParentToolUseID
+	case *UserToolResultMessage:
+		return m.ParentToolUseID
+	case *UserContentMessage:
+		return m.ParentToolUseID
+	case *UserReplayMessage:
+		return m.ParentToolUseID
+	case *StreamEventMessage:
+		return m.ParentToolUseID
+	}
+	return Nullable[string]{}
+}
@@ -0,0 +1,101 @@
+package ccprotocol_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// treeStream is one turn where the top level spawns a subagent that runs a
+// Bash command and a nested subagent. The top-level message msg_001 is
+// emitted as two assistant messages (one per block) with a stream event in
+// between.
+const treeStream = `{"type":"assistant","message":{"content":[{"type":"text","text":"Delegating."}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":100,"output_tokens":1}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u1"}
+{"type":"stream_event","event":{"type":"content_block_stop","index":0},"session_id":"abc","parent_tool_use_id":null,"uuid":"e1"}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_task_1","name":"Task","input":{"description":"Explore","prompt":"List files","subagent_type":"general-purpose"}}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":100,"output_tokens":20}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u2"}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_bash_1","name":"Bash","input":{"command":"ls"}}],"id":"msg_002","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":50,"output_tokens":5}},"parent_tool_use_id":"toolu_task_1","session_id":"abc","uuid":"u3"}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_bash_1","content":"a.go"}]},"parent_tool_use_id":"toolu_task_1","session_id":"abc","uuid":"u4","tool_use_result":{}}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_task_2","name":"Task","input":{"description":"Read","prompt":"Read a.go","subagent_type":"Explore"}}],"id":"msg_003","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":60,"output_tokens":6}},"parent_tool_use_id":"toolu_task_1","session_id":"abc","uuid":"u5"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"It is a Go file."}],"id":"msg_004","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":30,"output_tokens":3}},"parent_tool_use_id":"toolu_task_2","session_id":"abc","uuid":"u6"}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_task_2","content":"It is a Go file."}]},"parent_tool_use_id":"toolu_task_1","session_id":"abc","uuid":"u7","tool_use_result":{}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"One Go file."}],"id":"msg_005","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":70,"output_tokens":4}},"parent_tool_use_id":"toolu_task_1","session_id":"abc","uuid":"u8"}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_task_1","content":"One Go file."}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"u9","tool_use_result":{}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"There is one Go file."}],"id":"msg_006","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{"input_tokens":200,"output_tokens":8}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u10"}
+{"type":"result","subtype":"success","is_error":false,"duration_ms":52,"duration_api_ms":18,"num_turns":2,"result":"There is one Go file.","stop_reason":null,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[],"fast_mode_state":"off","uuid":"u11"}
+`
+
+func TestMessageTree(t *testing.T) {
+	turn, err := ReadTurn(NewReader(strings.NewReader(treeStream)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tree := turn.Tree()
+
+	var shape []string
+	tree.Root.Walk(func(n *TreeNode, depth int) {
+		id := "root"
+		if n.ToolUse != nil {
+			id = n.ToolUse.Name + " " + n.ToolUse.ID
+		}
+		shape = append(shape, strings.Repeat("  ", depth)+id)
+	})
+	want := []string{"root", "  Task toolu_task_1", "    Task toolu_task_2"}
+	if !reflect.DeepEqual(shape, want) {
+		t.Errorf("tree shape = %q, want %q", shape, want)
+	}
+
+	if got := len(tree.Root.Messages); got != 6 {
+		t.Errorf("len(Root.Messages) = %d, want 6", got)
+	}
+	sub := tree.Node("toolu_task_1")
+	if sub == nil || sub != tree.Root.Children[0] {
+		t.Fatalf("Node(toolu_task_1) = %v, want the root's child", sub)
+	}
+	if got := len(sub.Assistant()); got != 3 {
+		t.Errorf("len(Assistant()) of toolu_task_1 = %d, want 3", got)
+	}
+	if sub.Result == nil || sub.Result.Content != "One Go file." {
+		t.Errorf("Result of toolu_task_1 = %+v", sub.Result)
+	}
+	nested := tree.Node("toolu_task_2")
+	if nested == nil || len(nested.Messages) != 1 || nested.Result == nil {
+		t.Errorf("Node(toolu_task_2) = %+v, want one message and a result", nested)
+	}
+	if tree.Node("toolu_bash_1") != nil {
+		t.Error("Node(toolu_bash_1) != nil, want no node for a tool without subagent messages")
+	}
+
+	// msg_001 counts once, with the usage of its last assistant message.
+	tests := []struct {
+		node          *TreeNode
+		input, output int
+	}{
+		{nested, 30, 3},
+		{sub, 50 + 60 + 70 + 30, 5 + 6 + 4 + 3},
+		{&tree.Root, 100 + 200 + 50 + 60 + 70 + 30, 20 + 8 + 5 + 6 + 4 + 3},
+	}
+	for i, tt := range tests {
+		u := tt.node.Usage()
+		if u.InputTokens != tt.input || u.OutputTokens != tt.output {
+			t.Errorf("tests[%d]: Usage() = %d in / %d out, want %d / %d", i, u.InputTokens, u.OutputTokens, tt.input, tt.output)
+		}
+	}
+}
+
+func TestMessageTree_UnknownParent(t *testing.T) {
+	msg := `{"type":"assistant","message":{"content":[{"type":"text","text":"orphan"}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":"toolu_gone","session_id":"abc","uuid":"u1"}`
+	m, err := DecodeMessage([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := BuildMessageTree([]IsMessage{m})
+	n := tree.Node("toolu_gone")
+	if n == nil || len(tree.Root.Children) != 1 || tree.Root.Children[0] != n {
+		t.Fatalf("Node(toolu_gone) = %v, want a child of the root", n)
+	}
+	if n.ToolUse.ID != "toolu_gone" || n.ToolUse.Name != "" || len(n.Messages) != 1 {
+		t.Errorf("Node(toolu_gone) = %+v", n)
+	}
+}
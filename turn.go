@@ -0,0 +1,169 @@
+package ccprotocol
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Turn aggregates the output messages of a single turn, from the first
+// message after the user input up to and including the result message.
+// Build one with Add (or ReadTurn) and inspect it once Done reports true.
+type Turn struct {
+	Messages           []IsMessage              // All messages in arrival order
+	Assistant          []*AssistantMessage      // Assistant messages (including subagent ones)
+	PermissionRequests []*ControlRequestMessage // can_use_tool control requests
+	Result             ResultMessage            // The turn-ending result; nil until Done
+
+	toolCalls []ToolCall
+	toolIndex map[string]int // tool_use ID -> index in toolCalls
+}
+
+// ToolCall pairs a tool_use block with its tool_result block.
+type ToolCall struct {
+	Use             ToolUseBlock     // The tool_use block (only ID is set if the result arrived without one)
+	Result          *ToolResultBlock // The matching tool_result block; nil if none was received
+	ToolUseResult   any              // tool_use_result of the user message carrying Result
+	ParentToolUseID Nullable[string] // Parent tool use ID of the assistant message (subagent calls)
+}
+
+// Add records m in the turn and reports whether it ended the turn.
+func (t *Turn) Add(m IsMessage) (done bool) {
+	t.Messages = append(t.Messages, m)
+	switch m := m.(type) {
+	case *AssistantMessage:
+		t.Assistant = append(t.Assistant, m)
+		for _, b := range m.Message.Content {
+			if tu, ok := b.(ToolUseBlock); ok {
+				tc := t.toolCall(tu.ID)
+				tc.Use = tu
+				tc.ParentToolUseID = m.ParentToolUseID
+			}
+		}
+	case *UserToolResultMessage:
+		for i := range m.Message.Content {
+			b := &m.Message.Content[i]
+			tc := t.toolCall(b.ToolUseID)
+			tc.Result = b
+			tc.ToolUseResult = m.ToolUseResult
+		}
+	case *ControlRequestMessage:
+		if _, ok := m.Request.(CanUseToolRequest); ok {
+			t.PermissionRequests = append(t.PermissionRequests, m)
+		}
+	case ResultMessage:
+		t.Result = m
+		return true
+	}
+	return false
+}
+
+// toolCall returns the ToolCall for id, creating it if needed.
+func (t *Turn) toolCall(id string) *ToolCall {
+	if i, ok := t.toolIndex[id]; ok {
+		return &t.toolCalls[i]
+	}
+	if t.toolIndex == nil {
+		t.toolIndex = make(map[string]int)
+	}
+	t.toolIndex[id] = len(t.toolCalls)
+	t.toolCalls = append(t.toolCalls, ToolCall{Use: ToolUseBlock{ID: id}})
+	return &t.toolCalls[len(t.toolCalls)-1]
+}
+
+// Done reports whether the result message has been received.
+func (t *Turn) Done() bool {
+	return t.Result != nil
+}
+
+// Text returns the text blocks of the top-level assistant messages (those
+// without a parent tool use), joined by newlines.
+func (t *Turn) Text() string {
+	var parts []string
+	for _, m := range t.Assistant {
+		if m.ParentToolUseID.Valid {
+			continue
+		}
+		for _, b := range m.Message.Content {
+			if tb, ok := b.(TextBlock); ok {
+				parts = append(parts, tb.Text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ToolCalls returns the tool calls of the turn in the order their tool_use
+// blocks (or, for orphaned results, tool_result blocks) arrived.
+func (t *Turn) ToolCalls() []ToolCall {
+	return t.toolCalls
+}
+
+// Tree returns the subagent hierarchy of the turn's messages.
+func (t *Turn) Tree() *MessageTree {
+	return BuildMessageTree(t.Messages)
+}
+
+// Errors returns the problems reported during the turn: failed tool results,
+// permission denials and the errors of an error result. It returns nil for a
+// clean turn.
+func (t *Turn) Errors() []string {
+	var errs []string
+	for _, tc := range t.toolCalls {
+		if tc.Result != nil && tc.Result.IsError {
+			errs = append(errs, fmt.Sprintf("%s (%s): %s", tc.Use.Name, tc.Use.ID, toolResultText(tc.Result.Content)))
+		}
+	}
+	if t.Result == nil {
+		return errs
+	}
+	c := t.Result.Common()
+	if t.Result.Outcome() == OutcomeMaxTurns {
+		errs = append(errs, fmt.Sprintf("%s after %v turns", SubtypeErrorMaxTurns, c.NumTurns))
+	}
+	errs = append(errs, resultErrors(t.Result)...)
+	for _, d := range c.PermissionDenials {
+		errs = append(errs, fmt.Sprintf("permission denied: %s (%s)", d.ToolName, d.ToolUseID))
+	}
+	return errs
+}
+
+// toolResultText flattens tool_result content (a string or an array of text
+// blocks) to a string.
+func toolResultText(content any) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []any:
+		var parts []string
+		for _, item := range c {
+			if m, ok := item.(map[string]any); ok {
+				if s, ok := m["text"].(string); ok {
+					parts = append(parts, s)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	default:
+		return fmt.Sprint(content)
+	}
+}
+
+// ReadTurn reads messages from r until a result message ends the turn.
+// On error it returns the partial turn along with the error; io.EOF before
+// the result is returned as io.ErrUnexpectedEOF.
+func ReadTurn(r *Reader) (*Turn, error) {
+	t := &Turn{}
+	for {
+		m, err := r.Read()
+		if err == io.EOF {
+			return t, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return t, err
+		}
+		if t.Add(m) {
+			return t, nil
+		}
+	}
+}
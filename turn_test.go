@@ -0,0 +1,88 @@
+package ccprotocol_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/hrntknr/claudecodeprotocol"
+)
+
+// turnStream is one turn: text + Bash call, a permission prompt, a failed
+// tool result, a subagent message, a final text and an error result.
+const turnStream = `{"type":"system","subtype":"status","status":null,"permissionMode":"default","uuid":"u0","session_id":"abc"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_001","name":"Bash","input":{"command":"ls"}}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u1"}
+{"type":"control_request","request_id":"req_001","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"ls"},"tool_use_id":"toolu_001"}}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_001","content":"ls: permission denied","is_error":true}]},"parent_tool_use_id":null,"session_id":"abc","uuid":"u2","tool_use_result":"Error: ls: permission denied"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"subagent text"}],"id":"msg_002","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":"toolu_000","session_id":"abc","uuid":"u3"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"It failed."}],"id":"msg_003","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u4"}
+{"type":"result","subtype":"error_during_execution","is_error":false,"duration_ms":52,"duration_api_ms":18,"num_turns":1,"session_id":"abc","total_cost_usd":0,"usage":{},"modelUsage":{},"permission_denials":[{"tool_name":"Write","tool_use_id":"toolu_002","tool_input":{}}],"fast_mode_state":"off","uuid":"u5","errors":["API error"]}
+`
+
+func TestReadTurn(t *testing.T) {
+	turn, err := ReadTurn(NewReader(strings.NewReader(turnStream)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !turn.Done() {
+		t.Fatal("Done() = false, want true")
+	}
+	if len(turn.Messages) != 7 {
+		t.Errorf("len(Messages) = %d, want 7", len(turn.Messages))
+	}
+	if len(turn.Assistant) != 3 {
+		t.Errorf("len(Assistant) = %d, want 3", len(turn.Assistant))
+	}
+	if _, ok := turn.Result.(*ResultErrorMessage); !ok {
+		t.Errorf("Result type = %T, want *ResultErrorMessage", turn.Result)
+	}
+
+	if got, want := turn.Text(), "Let me check.\nIt failed."; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+
+	calls := turn.ToolCalls()
+	if len(calls) != 1 {
+		t.Fatalf("len(ToolCalls()) = %d, want 1", len(calls))
+	}
+	if calls[0].Use.Name != "Bash" || calls[0].Result == nil || calls[0].Result.ToolUseID != "toolu_001" {
+		t.Errorf("ToolCalls()[0] = %+v, want Bash call paired with its result", calls[0])
+	}
+	if calls[0].ToolUseResult != "Error: ls: permission denied" {
+		t.Errorf("ToolUseResult = %v", calls[0].ToolUseResult)
+	}
+
+	if len(turn.PermissionRequests) != 1 || turn.PermissionRequests[0].RequestID != "req_001" {
+		t.Errorf("PermissionRequests = %v, want [req_001]", turn.PermissionRequests)
+	}
+
+	want := []string{
+		"Bash (toolu_001): ls: permission denied",
+		"API error",
+		"permission denied: Write (toolu_002)",
+	}
+	if got := turn.Errors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Errors() = %q, want %q", got, want)
+	}
+}
+
+func TestReadTurn_PendingToolCall(t *testing.T) {
+	input := `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_001","name":"Read","input":{"file_path":"/tmp/x"}}],"id":"msg_001","model":"m","role":"assistant","stop_reason":null,"stop_sequence":null,"type":"message","usage":{}},"parent_tool_use_id":null,"session_id":"abc","uuid":"u1"}`
+
+	turn, err := ReadTurn(NewReader(strings.NewReader(input)))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if turn.Done() {
+		t.Error("Done() = true, want false")
+	}
+	calls := turn.ToolCalls()
+	if len(calls) != 1 || calls[0].Result != nil {
+		t.Errorf("ToolCalls() = %+v, want one call without result", calls)
+	}
+	if got := turn.Errors(); got != nil {
+		t.Errorf("Errors() = %q, want nil", got)
+	}
+}
@@ -1,16 +1,14 @@
 package utils
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
-	"strings"
-	"syscall"
+	"io"
 	"testing"
-	"time"
 
 	ccprotocol "github.com/hrntknr/claudecodeprotocol"
+	"github.com/hrntknr/claudecodeprotocol/client"
 )
 
 // PermissionHandler handles permission prompts from --permission-prompt-tool stdio.
@@ -19,16 +17,10 @@ import (
 type PermissionHandler func(toolName string, input map[string]any) map[string]any
 
 // Session manages an interactive CLI process for multi-turn testing.
+// It wraps a client.Client and reports every error via t.Fatalf.
 type Session struct {
-	t     *testing.T
-	cmd   *exec.Cmd
-	stdin interface {
-		Write([]byte) (int, error)
-		Close() error
-	}
-	scanner           *bufio.Scanner
-	stderr            *strings.Builder
-	permissionHandler PermissionHandler
+	t      *testing.T
+	client *client.Client
 }
 
 // NewSession starts a Claude Code CLI process connected to the given stub API.
@@ -49,15 +41,12 @@ func NewSessionWithEnv(t *testing.T, baseURL string, extraEnv []string) *Session
 // extraEnv is a list of "KEY=VALUE" strings appended to the process environment.
 func NewSessionWithFlags(t *testing.T, baseURL string, extraFlags []string, extraEnv []string) *Session {
 	t.Helper()
-	args := []string{
-		"--input-format", "stream-json",
-		"--output-format", "stream-json",
-		"--dangerously-skip-permissions",
-		"--verbose",
-		"--no-session-persistence",
-	}
-	args = append(args, extraFlags...)
-	return startSession(t, baseURL, args, extraEnv)
+	return startSession(t, baseURL, client.Options{
+		DangerouslySkipPermissions: true,
+		NoSessionPersistence:       true,
+		Args:                       extraFlags,
+		Env:                        extraEnv,
+	})
 }
 
 // NewSessionWithPermissionHandler starts a CLI process with --permission-prompt-tool stdio
@@ -65,56 +54,40 @@ func NewSessionWithFlags(t *testing.T, baseURL string, extraFlags []string, extr
 // with subtype "can_use_tool" are automatically handled by the given handler.
 func NewSessionWithPermissionHandler(t *testing.T, baseURL string, handler PermissionHandler) *Session {
 	t.Helper()
-	args := []string{
-		"--input-format", "stream-json",
-		"--output-format", "stream-json",
-		"--permission-prompt-tool", "stdio",
-		"--verbose",
-		"--no-session-persistence",
+	s := startSession(t, baseURL, client.Options{
+		PermissionPromptTool: "stdio",
+		NoSessionPersistence: true,
+	})
+	if handler != nil {
+		// Every request is deferred to the handler.
+		policy := &ccprotocol.PermissionPolicy{Ask: func(_ context.Context, req ccprotocol.CanUseToolRequest) (ccprotocol.PermissionPayload, error) {
+			return permissionPayload(handler(req.ToolName, req.Input)), nil
+		}}
+		s.client.Controller().Handle(ccprotocol.ControlCanUseTool, policy.Handler())
 	}
-	s := startSession(t, baseURL, args, nil)
-	s.permissionHandler = handler
 	return s
 }
 
-func startSession(t *testing.T, baseURL string, args []string, extraEnv []string) *Session {
+// NewSessionWithPermissionPolicy starts a CLI process with --permission-prompt-tool stdio
+// whose can_use_tool requests are answered by policy during Read(). The
+// policy's Decisions() record each answer for assertions.
+func NewSessionWithPermissionPolicy(t *testing.T, baseURL string, policy *ccprotocol.PermissionPolicy) *Session {
 	t.Helper()
+	return startSession(t, baseURL, client.Options{
+		PermissionPolicy:     policy,
+		NoSessionPersistence: true,
+	})
+}
 
-	cmd := exec.Command("claude", args...)
-	cmd.Env = append(cmd.Environ(),
-		"ANTHROPIC_BASE_URL="+baseURL,
-	)
-	cmd.Env = append(cmd.Env, extraEnv...)
-	// Create a new process group so we can kill the entire group
-	// (including any teammate subprocesses) during cleanup.
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+func startSession(t *testing.T, baseURL string, opts client.Options) *Session {
+	t.Helper()
 
-	stdin, err := cmd.StdinPipe()
+	opts.Env = append([]string{"ANTHROPIC_BASE_URL=" + baseURL}, opts.Env...)
+	c, err := client.Start(context.Background(), opts)
 	if err != nil {
-		t.Fatalf("stdin pipe: %v", err)
-	}
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		t.Fatalf("stdout pipe: %v", err)
-	}
-
-	var stderrBuf strings.Builder
-	cmd.Stderr = &stderrBuf
-
-	if err := cmd.Start(); err != nil {
 		t.Fatalf("start: %v", err)
 	}
-
-	scanner := bufio.NewScanner(stdout)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-
-	return &Session{
-		t:       t,
-		cmd:     cmd,
-		stdin:   stdin,
-		scanner: scanner,
-		stderr:  &stderrBuf,
-	}
+	return &Session{t: t, client: c}
 }
 
 // Send writes input lines to the CLI's stdin.
@@ -122,23 +95,60 @@ func (s *Session) Send(lines ...string) {
 	s.t.Helper()
 	for _, line := range lines {
 		s.t.Logf("stdin: %s", line)
-		if _, err := s.stdin.Write([]byte(line + "\n")); err != nil {
+		if err := s.client.SendRaw(context.Background(), []byte(line)); err != nil {
 			s.t.Fatalf("write stdin: %v", err)
 		}
 	}
 }
 
 // Read reads output lines from stdout until a "result" message is received.
-// If a PermissionHandler is set (via NewSessionWithPermissionHandler), any
-// control_request with subtype "can_use_tool" is automatically responded to.
+// If a PermissionHandler or PermissionPolicy is set, any control_request with
+// subtype "can_use_tool" is automatically responded to.
 func (s *Session) Read() []json.RawMessage {
 	s.t.Helper()
 	return s.ReadUntil("result")
 }
 
+// ReadTurn reads output lines like Read() and aggregates them into a Turn.
+// Messages of unknown types are decoded leniently and kept in Turn.Messages.
+func (s *Session) ReadTurn() *ccprotocol.Turn {
+	s.t.Helper()
+	turn := &ccprotocol.Turn{}
+	for i, raw := range s.Read() {
+		msg, err := ccprotocol.DecodeMessage(raw, ccprotocol.Lenient())
+		if err != nil {
+			s.t.Fatalf("decode output[%d]: %v", i, err)
+		}
+		turn.Add(msg)
+	}
+	return turn
+}
+
+// Interrupt sends an interrupt control_request and reads output like Read()
+// until the result that ends the interrupted turn. The control_response
+// answering the request is included in the output. Call it only while a turn
+// is in progress; an idle CLI answers the request but emits no result.
+func (s *Session) Interrupt() []json.RawMessage {
+	s.t.Helper()
+	s.t.Logf("stdin: interrupt")
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.client.Controller().Request(context.Background(), ccprotocol.InterruptRequest{
+			ControlRequestBase: ccprotocol.ControlRequestBase{Subtype: ccprotocol.ControlInterrupt},
+		})
+		errc <- err
+	}()
+	output := s.Read()
+	if err := <-errc; err != nil {
+		s.t.Fatalf("interrupt: %v", err)
+	}
+	return output
+}
+
 // ReadUntil reads output lines from stdout until a message with one of the
-// specified types is received. Like Read(), if a PermissionHandler is set,
-// control_request messages are automatically responded to.
+// specified types is received. Like Read(), if a PermissionHandler or
+// PermissionPolicy is set, control_request messages are automatically
+// responded to.
 func (s *Session) ReadUntil(stopTypes ...string) []json.RawMessage {
 	s.t.Helper()
 	stopSet := make(map[string]bool, len(stopTypes))
@@ -146,71 +156,73 @@ func (s *Session) ReadUntil(stopTypes ...string) []json.RawMessage {
 		stopSet[st] = true
 	}
 	var output []json.RawMessage
-	for s.scanner.Scan() {
-		line := s.scanner.Bytes()
-		if len(line) == 0 {
-			continue
+	for {
+		msg, err := s.client.RecvRaw(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.t.Fatalf("%v", err)
 		}
-		cp := make([]byte, len(line))
-		copy(cp, line)
-		msg := json.RawMessage(cp)
 		output = append(output, msg)
 		s.t.Logf("output[%d]: %s", len(output)-1, string(msg))
+		s.checkSchema(len(output)-1, msg)
 
 		// Handle permission prompts from --permission-prompt-tool stdio.
-		if s.permissionHandler != nil {
-			s.tryRespondPermission(msg)
-		}
+		s.dispatchControl(msg)
 
 		if stopSet[extractType(msg)] {
 			break
 		}
 	}
-	if err := s.scanner.Err(); err != nil {
-		s.t.Fatalf("scan stdout: %v", err)
-	}
 	if len(output) == 0 {
 		s.t.Fatal("no output received from CLI")
 	}
 	return output
 }
 
-// tryRespondPermission checks if msg is a can_use_tool control_request and,
-// if so, calls the permission handler and writes the response to stdin.
-func (s *Session) tryRespondPermission(msg json.RawMessage) {
-	var m ccprotocol.ControlRequestMessage
-	if err := json.Unmarshal(msg, &m); err != nil {
+// checkSchema fails the test if msg is not a Message of the generated schema,
+// so scenario runs catch drift between the schema and the CLI.
+func (s *Session) checkSchema(i int, msg json.RawMessage) {
+	s.t.Helper()
+	js, err := cliSchema()
+	if err != nil {
+		s.t.Fatalf("%v", err)
+	}
+	if err := js.ValidateMessage(msg); err != nil {
+		s.t.Errorf("output[%d] does not match the schema: %v", i, err)
+	}
+}
+
+// dispatchControl passes a control message to the client's Controller, which
+// answers can_use_tool requests via the permission handler or policy. The message is
+// still returned to the test for assertions.
+func (s *Session) dispatchControl(raw json.RawMessage) {
+	switch extractType(raw) {
+	case string(ccprotocol.TypeControlRequest), string(ccprotocol.TypeControlResponse):
+	default:
 		return
 	}
-	if m.Type != ccprotocol.TypeControlRequest || m.Request.Subtype != ccprotocol.ControlCanUseTool {
+	msg, err := ccprotocol.DecodeMessage(raw, ccprotocol.Lenient())
+	if err != nil {
+		s.t.Logf("decode control message: %v", err)
 		return
 	}
+	s.client.Controller().Dispatch(msg)
+}
 
-	updatedInput := s.permissionHandler(m.Request.ToolName, m.Request.Input)
-
-	var payload ccprotocol.PermissionPayload
-	if updatedInput != nil {
-		payload = ccprotocol.PermissionPayload{
-			Behavior:     "allow",
-			UpdatedInput: updatedInput,
-		}
-	} else {
-		payload = ccprotocol.PermissionPayload{
+// permissionPayload converts a PermissionHandler result into a response:
+// allow with the updated input, or deny when it is nil.
+func permissionPayload(updatedInput map[string]any) ccprotocol.PermissionPayload {
+	if updatedInput == nil {
+		return ccprotocol.PermissionPayload{
 			Behavior: "deny",
 			Message:  "Denied by test",
 		}
 	}
-
-	resp, _ := json.Marshal(ccprotocol.ControlResponseMessage{
-		MessageBase: ccprotocol.MessageBase{Type: ccprotocol.TypeControlResponse},
-		Response: ccprotocol.ControlResponseBody{
-			Subtype:   "success",
-			RequestID: m.RequestID,
-			Response:  payload,
-		},
-	})
-	if _, err := s.stdin.Write(append(resp, '\n')); err != nil {
-		s.t.Fatalf("write permission response: %v", err)
+	return ccprotocol.PermissionPayload{
+		Behavior:     "allow",
+		UpdatedInput: updatedInput,
 	}
 }
 
@@ -218,21 +230,8 @@ func (s *Session) tryRespondPermission(msg json.RawMessage) {
 // If the process does not exit within 10 seconds (e.g. due to running
 // teammate subprocesses), it is killed via SIGKILL to the process group.
 func (s *Session) Close() {
-	s.stdin.Close()
-
-	done := make(chan error, 1)
-	go func() { done <- s.cmd.Wait() }()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			s.t.Logf("CLI exit: %v (stderr: %s)", err, s.stderr.String())
-		}
-	case <-time.After(10 * time.Second):
-		s.t.Logf("CLI did not exit within 10s, killing process group")
-		// Kill the entire process group to clean up child processes (teammates).
-		_ = syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
-		<-done
+	if err := s.client.Close(); err != nil {
+		s.t.Logf("%v (stderr: %s)", err, s.client.Stderr())
 	}
 }
 
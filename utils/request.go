@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// MessagesRequest is the typed view of a Messages API request body.
+type MessagesRequest struct {
+	Model     string           `json:"model"`
+	MaxTokens int              `json:"max_tokens"`
+	System    RequestContent   `json:"system"`
+	Messages  []RequestMessage `json:"messages"`
+	Tools     []RequestTool    `json:"tools"`
+	Thinking  *ThinkingConfig  `json:"thinking"`
+	Metadata  *RequestMetadata `json:"metadata"`
+}
+
+// RequestMessage is one entry of the messages array.
+type RequestMessage struct {
+	Role    string         `json:"role"`
+	Content RequestContent `json:"content"`
+}
+
+// RequestContent is a content field, which the API accepts as a string or as
+// a block array. A string decodes to a single text block.
+type RequestContent []RequestBlock
+
+func (c *RequestContent) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*c = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*c = RequestContent{{Type: "text", Text: s}}
+		return nil
+	}
+	var blocks []RequestBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// Text joins the text blocks with newlines.
+func (c RequestContent) Text() string {
+	var parts []string
+	for _, b := range c {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// RequestBlock is a content block of a request. Only the fields of its type
+// are set.
+type RequestBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// thinking
+	Thinking string `json:"thinking,omitempty"`
+
+	// tool_use
+	ID    string         `json:"id,omitempty"`
+	Name  string         `json:"name,omitempty"`
+	Input map[string]any `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string         `json:"tool_use_id,omitempty"`
+	Content   RequestContent `json:"content,omitempty"`
+	IsError   bool           `json:"is_error,omitempty"`
+}
+
+// RequestTool is a tool definition offered to the model.
+type RequestTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema,omitempty"`
+}
+
+// ThinkingConfig is the extended thinking setting of a request.
+type ThinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// RequestMetadata is the metadata object of a request.
+type RequestMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Parse decodes the recorded body into a MessagesRequest.
+func (r RecordedRequest) Parse() (MessagesRequest, error) {
+	var req MessagesRequest
+	data, err := json.Marshal(r.Body)
+	if err != nil {
+		return req, err
+	}
+	err = json.Unmarshal(data, &req)
+	return req, err
+}
+
+// LastUserMessage returns the last message with the user role, or nil.
+func (r MessagesRequest) LastUserMessage() *RequestMessage {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == "user" {
+			return &r.Messages[i]
+		}
+	}
+	return nil
+}
+
+// ToolResults returns the tool_result blocks of the last user message, i.e.
+// the results of the tools the previous response called.
+func (r MessagesRequest) ToolResults() []RequestBlock {
+	m := r.LastUserMessage()
+	if m == nil {
+		return nil
+	}
+	var results []RequestBlock
+	for _, b := range m.Content {
+		if b.Type == "tool_result" {
+			results = append(results, b)
+		}
+	}
+	return results
+}
+
+// ToolNames returns the names of the offered tools.
+func (r MessagesRequest) ToolNames() []string {
+	names := make([]string, len(r.Tools))
+	for i, t := range r.Tools {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Tool returns the offered tool with the given name, or nil.
+func (r MessagesRequest) Tool(name string) *RequestTool {
+	for i := range r.Tools {
+		if r.Tools[i].Name == name {
+			return &r.Tools[i]
+		}
+	}
+	return nil
+}
+
+// AssertToolsOffered checks that every named tool is offered in req.
+func AssertToolsOffered(t *testing.T, req MessagesRequest, names ...string) {
+	t.Helper()
+	offered := req.ToolNames()
+	for _, name := range names {
+		if !slices.Contains(offered, name) {
+			t.Errorf("tool %q not offered, tools: %v", name, offered)
+		}
+	}
+}
+
+// AssertToolsNotOffered checks that none of the named tools is offered in req.
+func AssertToolsNotOffered(t *testing.T, req MessagesRequest, names ...string) {
+	t.Helper()
+	offered := req.ToolNames()
+	for _, name := range names {
+		if slices.Contains(offered, name) {
+			t.Errorf("tool %q offered, want it removed", name)
+		}
+	}
+}
+
+// AssertSystemPromptContains checks that the system prompt of req contains
+// substr.
+func AssertSystemPromptContains(t *testing.T, req MessagesRequest, substr string) {
+	t.Helper()
+	if !strings.Contains(req.System.Text(), substr) {
+		t.Errorf("system prompt does not contain %q", substr)
+	}
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Schema validates JSON values against schema/protocol.schema.json. It
+// implements the keywords cmd/genschema emits.
+type Schema struct {
+	root map[string]any
+}
+
+// LoadSchema reads schema/protocol.schema.json of this module.
+func LoadSchema() (*Schema, error) {
+	_, file, _, _ := runtime.Caller(0)
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "schema", "protocol.schema.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read schema (run go generate): %w", err)
+	}
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// cliSchema is the schema the output of the installed CLI is validated
+// against: fields its version predates (see FieldMinVersion) are optional.
+var cliSchema = sync.OnceValues(func() (*Schema, error) {
+	js, err := LoadSchema()
+	if err != nil {
+		return nil, err
+	}
+	defs := js.root["$defs"].(map[string]any)
+	cur := CLIVersion()
+	for key, minVer := range FieldMinVersion {
+		name, field, _ := strings.Cut(key, ".")
+		def, ok := defs[name].(map[string]any)
+		if !ok || CLIVersionAtLeast(cur, minVer) {
+			continue
+		}
+		def["required"] = slices.DeleteFunc(asSlice(def["required"]), func(r any) bool { return r == field })
+	}
+	return js, nil
+})
+
+// Examples returns the examples of each definition by name.
+func (js *Schema) Examples() map[string][]any {
+	examples := map[string][]any{}
+	for name, def := range js.root["$defs"].(map[string]any) {
+		if ex := asSlice(def.(map[string]any)["examples"]); len(ex) > 0 {
+			examples[name] = ex
+		}
+	}
+	return examples
+}
+
+// Validate returns nil if v matches the definition name, or an error naming
+// the first mismatch. An empty name validates v as a Message.
+func (js *Schema) Validate(name string, v any) error {
+	s := js.root
+	if name != "" {
+		s = map[string]any{"$ref": "#/$defs/" + name}
+	}
+	return js.validate(s, v, "$")
+}
+
+// ValidateMessage validates data as a Message.
+func (js *Schema) ValidateMessage(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return js.Validate("", v)
+}
+
+// validate returns nil if v matches s, or an error naming the first
+// mismatch below path.
+func (js *Schema) validate(s map[string]any, v any, path string) error {
+	if r, ok := s["$ref"].(string); ok {
+		def, ok := js.root["$defs"].(map[string]any)[strings.TrimPrefix(r, "#/$defs/")].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unresolved $ref %s", path, r)
+		}
+		if err := js.validate(def, v, path); err != nil {
+			return err
+		}
+	}
+	if t, ok := s["type"]; ok && !matchesType(t, v) {
+		return fmt.Errorf("%s: %v is not of type %v", path, v, t)
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fmt.Errorf("%s: %v is not %v", path, v, c)
+	}
+	if e, ok := s["enum"].([]any); ok && !containsValue(e, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, e)
+	}
+	if n, ok := s["not"].(map[string]any); ok && js.validate(n, v, path) == nil {
+		return fmt.Errorf("%s: %v matches a schema it must not match", path, v)
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		var errs []string
+		for _, sub := range anyOf {
+			err := js.validate(sub.(map[string]any), v, path)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if errs != nil {
+			return fmt.Errorf("%s: no variant matches:\n  %s", path, strings.Join(errs, "\n  "))
+		}
+	}
+	if obj, ok := v.(map[string]any); ok {
+		for _, r := range asSlice(s["required"]) {
+			if _, ok := obj[r.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, r)
+			}
+		}
+		props, _ := s["properties"].(map[string]any)
+		for k, val := range obj {
+			if ps, ok := props[k].(map[string]any); ok {
+				if err := js.validate(ps, val, path+"."+k); err != nil {
+					return err
+				}
+			} else if ap, ok := s["additionalProperties"].(map[string]any); ok {
+				if err := js.validate(ap, val, path+"."+k); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if items, ok := s["items"].(map[string]any); ok {
+		for i, val := range asSlice(v) {
+			if err := js.validate(items, val, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(t, v any) bool {
+	for _, name := range asSlice(t) {
+		switch name {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]any); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]any); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsValue(list []any, v any) bool {
+	for _, e := range list {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// asSlice returns v as a slice; a single value becomes a one-element slice.
+func asSlice(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
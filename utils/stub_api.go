@@ -8,12 +8,39 @@ import (
 	"net/http/httptest"
 	"strings"
 	"sync"
+	"testing"
+	"time"
 )
 
 // SSEEvent represents a single SSE event to send to the client.
+// An event with Stall set is not sent: the stub stops writing at that point
+// and holds the stream open until the client disconnects or the server is
+// closed, so a test can interrupt the turn mid-stream. If Release is set and
+// gets closed first, the stub resumes with the events after the stall.
+//
+// The remaining fields inject stream faults. Delay waits before the event is
+// written. An event with Close set is not sent: the stub drops the connection
+// without ending the stream (see Truncate). An event with Raw set writes Raw
+// verbatim instead of Event and Data, e.g. a malformed data: line.
 type SSEEvent struct {
-	Event string
-	Data  map[string]any
+	Event   string
+	Data    map[string]any
+	Stall   bool
+	Release <-chan struct{}
+	Delay   time.Duration
+	Close   bool
+	Raw     string
+
+	httpError *httpError
+}
+
+// httpError is a non-streaming response (see HTTPErrorResponse and
+// HTTPRawResponse). raw, when set, is sent verbatim instead of body.
+type httpError struct {
+	status int
+	header map[string]string
+	body   map[string]any
+	raw    string
 }
 
 // ToolCall describes a single tool invocation for use in MultiToolUseResponse.
@@ -33,23 +60,44 @@ type RecordedRequest struct {
 // The first request gets Responses[0], the second gets Responses[1], etc.
 // Extra requests beyond the list repeat the last response.
 //
+// Rules, when set, replace the Responses queue: each request is answered by
+// the first rule that matches it (see Rule). A request no rule matches gets
+// an invalid_request_error response and fails T.
+//
+// Responder, when set, replaces both: it builds the response of each request
+// from its content (see RecordedRequest.Parse). A nil result is treated like
+// a request no rule matches.
+//
+// T is required with Rules or Responder; Start panics without it.
+//
 // StaticPages maps URL paths to static HTML content, served as GET requests.
 // This allows testing tools like WebFetch that need to fetch external URLs.
 type StubAPIServer struct {
 	Responses   [][]SSEEvent
+	Rules       []Rule
+	Responder   func(req RecordedRequest) []SSEEvent
+	T           testing.TB
 	StaticPages map[string]string
 
-	server   *httptest.Server
-	mu       sync.Mutex
-	reqCount int
-	requests []RecordedRequest
+	server    *httptest.Server
+	stop      chan struct{}
+	closeOnce sync.Once
+	stalled   chan struct{}
+	mu        sync.Mutex
+	reqCount  int
+	requests  []RecordedRequest
 }
 
 // Start creates and starts the stub HTTP server.
 func (s *StubAPIServer) Start() {
+	if s.T == nil && (s.Responder != nil || len(s.Rules) > 0) {
+		panic("stub: T is required with Rules or Responder")
+	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /v1/messages", s.handleMessages)
 	mux.HandleFunc("GET /static/", s.handleStatic)
+	s.stop = make(chan struct{})
+	s.stalled = make(chan struct{}, 16)
 	s.server = httptest.NewServer(mux)
 }
 
@@ -65,11 +113,16 @@ func (s *StubAPIServer) handleStatic(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
-// Close shuts down the stub server.
+// Close shuts down the stub server, releasing any stalled streams.
+// It is safe to call more than once.
 func (s *StubAPIServer) Close() {
-	if s.server != nil {
-		s.server.Close()
+	if s.server == nil {
+		return
 	}
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.server.Close()
+	})
 }
 
 // URL returns the base URL of the stub server.
@@ -77,6 +130,13 @@ func (s *StubAPIServer) URL() string {
 	return s.server.URL
 }
 
+// Stalled returns a channel that receives a value each time a response
+// reaches a stall event (see SSEEvent.Stall). Everything before the stall has
+// been flushed to the CLI by then.
+func (s *StubAPIServer) Stalled() <-chan struct{} {
+	return s.stalled
+}
+
 // Requests returns a copy of all recorded requests.
 func (s *StubAPIServer) Requests() []RecordedRequest {
 	s.mu.Lock()
@@ -86,6 +146,23 @@ func (s *StubAPIServer) Requests() []RecordedRequest {
 	return cp
 }
 
+// MessagesRequests returns the parsed recorded requests, skipping the
+// internal haiku requests the CLI makes (see handleMessages).
+func (s *StubAPIServer) MessagesRequests(t *testing.T) []MessagesRequest {
+	t.Helper()
+	var reqs []MessagesRequest
+	for _, rec := range s.Requests() {
+		req, err := rec.Parse()
+		if err != nil {
+			t.Fatalf("parse recorded request: %v", err)
+		}
+		if !strings.Contains(req.Model, "haiku") {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
 // RequestCount returns the number of requests received so far.
 func (s *StubAPIServer) RequestCount() int {
 	s.mu.Lock()
@@ -114,7 +191,37 @@ func (s *StubAPIServer) handleMessages(w http.ResponseWriter, r *http.Request) {
 	// before the main request. These are answered with a dummy "ok" response
 	// without consuming from the Responses queue.
 	if model, _ := body["model"].(string); strings.Contains(model, "haiku") {
-		s.writeSSE(w, flusher, TextResponse("ok"))
+		s.writeSSE(w, r, flusher, TextResponse("ok"))
+		return
+	}
+
+	if s.Responder != nil || len(s.Rules) > 0 {
+		s.mu.Lock()
+		s.reqCount++
+		s.mu.Unlock()
+		rec := RecordedRequest{Body: body}
+		req, err := rec.Parse()
+		if err != nil {
+			s.T.Errorf("stub: parse request: %v", err)
+			s.writeHTTPError(w, HTTPErrorResponse(http.StatusBadRequest, nil, "invalid_request_error", "stub: malformed request")[0].httpError)
+			return
+		}
+		var events []SSEEvent
+		if s.Responder != nil {
+			events = s.Responder(rec)
+		} else {
+			for _, rule := range s.Rules {
+				if rule.matches(req) {
+					events = rule.Response
+					break
+				}
+			}
+		}
+		if events == nil {
+			s.writeUnmatched(w, req)
+			return
+		}
+		s.writeSSE(w, r, flusher, events)
 		return
 	}
 
@@ -126,15 +233,80 @@ func (s *StubAPIServer) handleMessages(w http.ResponseWriter, r *http.Request) {
 	s.reqCount++
 	s.mu.Unlock()
 
-	s.writeSSE(w, flusher, s.Responses[idx])
+	s.writeSSE(w, r, flusher, s.Responses[idx])
 }
 
-func (s *StubAPIServer) writeSSE(w http.ResponseWriter, flusher http.Flusher, events []SSEEvent) {
+// writeUnmatched fails T and answers a request that has no scripted
+// response with an API error, which ends the turn.
+func (s *StubAPIServer) writeUnmatched(w http.ResponseWriter, req MessagesRequest) {
+	var last string
+	if m := req.LastUserMessage(); m != nil {
+		last = m.Content.Text()
+	}
+	s.T.Errorf("stub: no response for request: model=%q last user message=%q", req.Model, last)
+	s.writeHTTPError(w, HTTPErrorResponse(http.StatusBadRequest, nil, "invalid_request_error", "stub: no response for the request")[0].httpError)
+}
+
+// writeHTTPError writes a non-streaming error response.
+func (s *StubAPIServer) writeHTTPError(w http.ResponseWriter, e *httpError) {
+	if e.body != nil {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/html")
+	}
+	for k, v := range e.header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(e.status)
+	if e.body != nil {
+		json.NewEncoder(w).Encode(e.body)
+		return
+	}
+	io.WriteString(w, e.raw)
+}
+
+func (s *StubAPIServer) writeSSE(w http.ResponseWriter, r *http.Request, flusher http.Flusher, events []SSEEvent) {
+	if len(events) > 0 && events[0].httpError != nil {
+		s.writeHTTPError(w, events[0].httpError)
+		return
+	}
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
 	for _, e := range events {
+		if e.Delay > 0 {
+			select {
+			case <-time.After(e.Delay):
+			case <-r.Context().Done():
+				return
+			case <-s.stop:
+				return
+			}
+		}
+		if e.Close {
+			// Aborting the handler closes the connection without the
+			// terminating chunk, so the client sees a cut stream.
+			panic(http.ErrAbortHandler)
+		}
+		if e.Raw != "" {
+			fmt.Fprint(w, e.Raw)
+			flusher.Flush()
+			continue
+		}
+		if e.Stall {
+			select {
+			case s.stalled <- struct{}{}:
+			default:
+			}
+			select {
+			case <-e.Release:
+				continue
+			case <-r.Context().Done():
+			case <-s.stop:
+			}
+			return
+		}
 		data, err := json.Marshal(e.Data)
 		if err != nil {
 			return
@@ -387,6 +559,14 @@ func StopSequenceTextResponse(text, stopSequence string) []SSEEvent {
 	return events
 }
 
+// StalledTextResponse builds an SSE event sequence that starts streaming a
+// text block and then stalls (see SSEEvent.Stall) before the block ends.
+func StalledTextResponse(text string) []SSEEvent {
+	events := []SSEEvent{messageStartEvent()}
+	events = append(events, textBlockEvents(0, text)[:2]...)
+	return append(events, SSEEvent{Stall: true})
+}
+
 // ErrorSSEResponse builds a single SSE error event (API-level error, not tool error).
 func ErrorSSEResponse(errorType, message string) []SSEEvent {
 	return []SSEEvent{
@@ -402,3 +582,51 @@ func ErrorSSEResponse(errorType, message string) []SSEEvent {
 		},
 	}
 }
+
+// HTTPErrorResponse builds a response that fails the request with an HTTP
+// status instead of a stream, with an API error body of the given type and
+// message. header is added to the response, e.g. retry-after for a 429.
+// The result is a whole response; appending events to it has no effect.
+func HTTPErrorResponse(status int, header map[string]string, errorType, message string) []SSEEvent {
+	return []SSEEvent{{httpError: &httpError{
+		status: status,
+		header: header,
+		body: map[string]any{
+			"type": "error",
+			"error": map[string]any{
+				"type":    errorType,
+				"message": message,
+			},
+		},
+	}}}
+}
+
+// HTTPRawResponse builds a response that fails the request with an HTTP
+// status and a body that is sent as is, e.g. the HTML error page of a proxy
+// in front of the API. The Content-Type defaults to text/html; header can
+// override it. Like HTTPErrorResponse, the result is a whole response.
+func HTTPRawResponse(status int, header map[string]string, body string) []SSEEvent {
+	return []SSEEvent{{httpError: &httpError{
+		status: status,
+		header: header,
+		raw:    body,
+	}}}
+}
+
+// Truncate returns the first n events of a response followed by a Close
+// event, so the connection drops mid-stream. If n exceeds the number of
+// events, the whole response is sent before the drop.
+func Truncate(events []SSEEvent, n int) []SSEEvent {
+	out := append([]SSEEvent{}, events[:min(n, len(events))]...)
+	return append(out, SSEEvent{Close: true})
+}
+
+// Delayed returns a copy of a response that waits d before every event but
+// the first.
+func Delayed(events []SSEEvent, d time.Duration) []SSEEvent {
+	out := append([]SSEEvent{}, events...)
+	for i := 1; i < len(out); i++ {
+		out[i].Delay = d
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+)
+
+// Rule routes API requests to a scripted response (see StubAPIServer.Rules).
+// A rule matches when every condition that is set holds; a rule without
+// conditions matches every request.
+type Rule struct {
+	// Model matches a substring of the request model.
+	Model string
+	// System matches a substring of the system prompt.
+	System string
+	// LastUser matches a substring of the text of the last user message.
+	LastUser string
+	// ToolResult matches the tool_use_id of a tool_result block in the last
+	// user message, i.e. the request that follows that tool's execution.
+	ToolResult string
+	// Tools lists tool names that must all be offered in the request.
+	Tools []string
+
+	Response []SSEEvent
+}
+
+func (r Rule) matches(req MessagesRequest) bool {
+	if r.Model != "" && !strings.Contains(req.Model, r.Model) {
+		return false
+	}
+	if r.System != "" && !strings.Contains(req.System.Text(), r.System) {
+		return false
+	}
+	if r.LastUser != "" {
+		m := req.LastUserMessage()
+		if m == nil || !strings.Contains(m.Content.Text(), r.LastUser) {
+			return false
+		}
+	}
+	if r.ToolResult != "" && !slices.ContainsFunc(req.ToolResults(), func(b RequestBlock) bool {
+		return b.ToolUseID == r.ToolResult
+	}) {
+		return false
+	}
+	names := req.ToolNames()
+	for _, name := range r.Tools {
+		if !slices.Contains(names, name) {
+			return false
+		}
+	}
+	return true
+}